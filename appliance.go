@@ -1,8 +1,7 @@
 package main
 
 import (
-	"strconv"
-
+	"github.com/1and1/oneandone-cloudserver-sdk-go"
 	"github.com/codegangsta/cli"
 )
 
@@ -29,7 +28,7 @@ func init() {
 				{
 					Name:   "list",
 					Usage:  "Lists available server appliances.",
-					Flags:  queryFlags,
+					Flags:  append(append(queryFlags, osFilterFlags...), applianceFilterFlags...),
 					Action: listAppliances,
 				},
 			},
@@ -45,18 +44,27 @@ func showAppliance(ctx *cli.Context) {
 }
 
 func listAppliances(ctx *cli.Context) {
-	saps, err := api.ListServerAppliances(getQueryParams(ctx))
-	exitOnError(err)
+	filtered, page, perPage, sort, query, fields := getFilteredQueryParams(ctx, append(osFilterFlags, applianceFilterFlags...))
+	var saps []oneandone.ServerAppliance
+	var err error
+	if filtered {
+		saps, err = api.ListServerAppliances(0, 0, sort, query, "")
+		exitOnError(err)
+		matching := []oneandone.ServerAppliance{}
+		for i := range saps {
+			if applianceMatches(ctx, &saps[i]) {
+				matching = append(matching, saps[i])
+			}
+		}
+		from, to := pageRange(page, perPage, len(matching))
+		saps = matching[from:to]
+	} else {
+		saps, err = api.ListServerAppliances(page, perPage, sort, query, fields)
+		exitOnError(err)
+	}
 	data := make([][]string, len(saps))
 	for i, a := range saps {
-		var arch string
-		ar, isNum := a.Architecture.(float64)
-		if isNum {
-			arch = strconv.FormatFloat(ar, 'f', -1, 64)
-		} else {
-			arch, _ = a.Architecture.(string)
-		}
-		data[i] = []string{a.Id, a.Name, a.Type, a.OsVersion, arch}
+		data[i] = []string{a.Id, a.Name, a.Type, a.OsVersion, formatArchitecture(a.Architecture)}
 	}
 	header := []string{"ID", "Name", "Type", "OS", "Architecture"}
 	output(ctx, saps, "", false, &header, &data)
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/1and1/oneandone-cloudserver-sdk-go"
+	"github.com/codegangsta/cli"
+)
+
+// Flags filtering the OS of server appliances and DVD ISOs.
+var osFilterFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "os-family",
+		Usage: "Only list images of the OS family, e.g. Linux or Windows.",
+	},
+	cli.StringFlag{
+		Name:  "os",
+		Usage: "Only list images of the OS, e.g. Ubuntu or Debian.",
+	},
+	cli.StringFlag{
+		Name:  "version",
+		Usage: "Only list images of the OS version or its minor versions, e.g. 12 or 16.04.",
+	},
+	cli.StringFlag{
+		Name:  "arch",
+		Usage: "Only list images of the architecture: 32 or 64.",
+	},
+	cli.StringFlag{
+		Name:  "type",
+		Usage: "Only list images of the type, IMAGE or ISO for server appliances.",
+	},
+}
+
+// Flags filtering server appliances by the fields DVD ISOs don't have.
+var applianceFilterFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "server-type",
+		Usage: "Only list appliances compatible with the server type: cloud or baremetal.",
+	},
+	cli.StringFlag{
+		Name:  "category",
+		Usage: "Only list appliances of the category.",
+	},
+}
+
+// Returns the query parameters of a list command with the filter flags. The filters are applied to
+// the API results, so they need all fields, and paging is applied after filtering: the API returns
+// all images sorted and queried, and page and perPage select from the matching ones.
+func getFilteredQueryParams(ctx *cli.Context, filterFlags []cli.Flag) (filtered bool, page, perPage int, sort, query, fields string) {
+	page, perPage, sort, query, fields = getQueryParams(ctx)
+	for _, f := range filterFlags {
+		if name := f.(cli.StringFlag).Name; ctx.String(name) != "" {
+			if fields != "" {
+				exitOnError(fmt.Errorf("--%s cannot be combined with --fields", name))
+			}
+			filtered = true
+		}
+	}
+	return filtered, page, perPage, sort, query, fields
+}
+
+// Returns the range of the page of n matching items. Without --perpage, all items are on the page.
+func pageRange(page, perPage, n int) (from, to int) {
+	if perPage <= 0 {
+		return 0, n
+	}
+	if page <= 0 {
+		page = 1
+	}
+	from = (page - 1) * perPage
+	if from > n {
+		from = n
+	}
+	to = from + perPage
+	if to > n {
+		to = n
+	}
+	return from, to
+}
+
+// OS of a server appliance or DVD ISO.
+type osImage struct {
+	family, os, osVersion, version, arch, imageType string
+}
+
+func formatArchitecture(arch interface{}) string {
+	if n, isNum := arch.(float64); isNum {
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	s, _ := arch.(string)
+	return s
+}
+
+func applianceImage(a *oneandone.ServerAppliance) osImage {
+	return osImage{a.OsFamily, a.Os, a.OsVersion, a.Version, formatArchitecture(a.Architecture), a.Type}
+}
+
+func dvdIsoImage(d *oneandone.DvdIso) osImage {
+	return osImage{d.OsFamily, d.Os, d.OsVersion, "", formatArchitecture(d.Architecture), d.Type}
+}
+
+// Returns the version number of the OS, e.g. 16.04 for OS Ubuntu and OS version Ubuntu16.04.
+func (img osImage) versionNumber() string {
+	v := img.osVersion
+	if len(v) >= len(img.os) && strings.EqualFold(v[:len(img.os)], img.os) {
+		v = v[len(img.os):]
+	}
+	return strings.TrimLeft(v, " -_")
+}
+
+// Reports whether the OS version is the version or one of its minor versions.
+func (img osImage) hasVersion(version string) bool {
+	for _, v := range []string{img.versionNumber(), img.version} {
+		if v != "" && (strings.EqualFold(v, version) || strings.HasPrefix(v, version+".")) {
+			return true
+		}
+	}
+	return false
+}
+
+func (img osImage) matches(ctx *cli.Context) bool {
+	if f := ctx.String("os-family"); f != "" && !strings.EqualFold(img.family, f) {
+		return false
+	}
+	if o := ctx.String("os"); o != "" && !strings.EqualFold(img.os, o) {
+		return false
+	}
+	if v := ctx.String("version"); v != "" && !img.hasVersion(v) {
+		return false
+	}
+	if a := ctx.String("arch"); a != "" && img.arch != a {
+		return false
+	}
+	if t := ctx.String("type"); t != "" && !strings.EqualFold(img.imageType, t) {
+		return false
+	}
+	return true
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func applianceMatches(ctx *cli.Context, a *oneandone.ServerAppliance) bool {
+	if t := ctx.String("server-type"); t != "" && !containsFold(a.ServerTypeCompatibility, t) {
+		return false
+	}
+	if c := ctx.String("category"); c != "" && !containsFold(a.Categories, c) {
+		return false
+	}
+	return applianceImage(a).matches(ctx)
+}
+
+// Compares dotted version numbers numerically, e.g. 16.10 is newer than 16.04 and 10 newer than 9.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y string
+		if i < len(as) {
+			x = as[i]
+		}
+		if i < len(bs) {
+			y = bs[i]
+		}
+		xn, xerr := strconv.Atoi(x)
+		yn, yerr := strconv.Atoi(y)
+		switch {
+		case xerr == nil && yerr == nil && xn != yn:
+			if xn < yn {
+				return -1
+			}
+			return 1
+		case (xerr != nil || yerr != nil) && x != y:
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// Resolves a symbolic appliance reference os[:version|latest[:arch]], e.g. ubuntu:latest or debian:12:64,
+// to the ID of the newest matching image compatible with the server type. Other values are returned unchanged.
+func resolveApplianceId(ref, serverType string) string {
+	if !strings.Contains(ref, ":") {
+		return ref
+	}
+	parts := strings.Split(ref, ":")
+	if len(parts) > 3 || parts[0] == "" {
+		exitOnError(fmt.Errorf("invalid appliance reference '%s', use os[:version|latest[:arch]], e.g. ubuntu:latest or debian:12:64", ref))
+	}
+	osName, version, arch := parts[0], "latest", ""
+	if len(parts) > 1 && parts[1] != "" {
+		version = parts[1]
+	}
+	if len(parts) > 2 {
+		arch = parts[2]
+	}
+
+	var appliances []oneandone.ServerAppliance
+	loadCatalog("server-appliances", true, func() (interface{}, error) { return api.ListServerAppliances() }, &appliances)
+	var candidates []*oneandone.ServerAppliance
+	for i := range appliances {
+		a := &appliances[i]
+		img := applianceImage(a)
+		if !strings.EqualFold(img.os, osName) && !strings.EqualFold(img.family, osName) {
+			continue
+		}
+		if (version != "latest" && !img.hasVersion(version)) || (arch != "" && img.arch != arch) {
+			continue
+		}
+		if !strings.EqualFold(a.Type, "IMAGE") ||
+			(len(a.ServerTypeCompatibility) > 0 && !containsFold(a.ServerTypeCompatibility, serverType)) {
+			continue
+		}
+		candidates = append(candidates, a)
+	}
+	if len(candidates) == 0 {
+		exitOnError(fmt.Errorf("no %s server image matches '%s', see 'oneandone appliance list --os %s'", serverType, ref, osName))
+	}
+	// Newest version first, 64-bit before 32-bit, then by name to be deterministic.
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := applianceImage(candidates[i]), applianceImage(candidates[j])
+		if c := compareVersions(a.versionNumber(), b.versionNumber()); c != 0 {
+			return c > 0
+		}
+		if a.arch != b.arch {
+			return a.arch > b.arch
+		}
+		return candidates[i].Name < candidates[j].Name
+	})
+	fmt.Fprintf(os.Stderr, "Using appliance '%s' (%s) for %s\n", candidates[0].Name, candidates[0].Id, ref)
+	return candidates[0].Id
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/1and1/oneandone-cloudserver-sdk-go"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"16.04", "16.04", 0},
+		{"16.10", "16.04", 1},
+		{"9", "10", -1},
+		{"12", "12.1", -1},
+		{"2019", "2016", 1},
+		{"7.9", "7.10", -1},
+		{"R2", "R1", 1},
+		{"", "1", -1},
+	}
+	for _, test := range tests {
+		if c := compareVersions(test.a, test.b); c != test.expected {
+			t.Errorf("compareVersions(%s, %s): expected %d, got %d", test.a, test.b, test.expected, c)
+		}
+		if c := compareVersions(test.b, test.a); c != -test.expected {
+			t.Errorf("compareVersions(%s, %s): expected %d, got %d", test.b, test.a, -test.expected, c)
+		}
+	}
+}
+
+const testAppliances = `[
+	{"id": "U1604", "name": "ubuntu1604-64std", "type": "IMAGE", "os_family": "Linux", "os": "Ubuntu",
+		"os_version": "Ubuntu16.04", "os_architecture": 64, "server_type_compatibility": ["cloud", "baremetal"]},
+	{"id": "U1804-32", "name": "ubuntu1804-32std", "type": "IMAGE", "os_family": "Linux", "os": "Ubuntu",
+		"os_version": "Ubuntu18.04", "os_architecture": 32, "server_type_compatibility": ["cloud"]},
+	{"id": "U1804", "name": "ubuntu1804-64std", "type": "IMAGE", "os_family": "Linux", "os": "Ubuntu",
+		"os_version": "Ubuntu18.04", "os_architecture": 64, "server_type_compatibility": ["cloud"]},
+	{"id": "U2004-ISO", "name": "ubuntu2004-iso", "type": "ISO", "os_family": "Linux", "os": "Ubuntu",
+		"os_version": "Ubuntu20.04", "os_architecture": 64},
+	{"id": "D9", "name": "debian9-64std", "type": "IMAGE", "os_family": "Linux", "os": "Debian",
+		"os_version": "Debian9", "os_architecture": 64},
+	{"id": "D10", "name": "debian10-64std", "type": "IMAGE", "os_family": "Linux", "os": "Debian",
+		"os_version": "Debian10", "os_architecture": 64},
+	{"id": "W2016", "name": "w2016-64std", "type": "IMAGE", "os_family": "Windows", "os": "WindowsDatacenter",
+		"os_version": "Windows2016", "os_architecture": 64}
+]`
+
+func TestResolveApplianceId(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, testAppliances)
+	}))
+	defer server.Close()
+	cacheDir, err := ioutil.TempDir("", "appliances")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(cacheDir)
+	os.Setenv("XDG_CACHE_HOME", cacheDir)
+	defer os.Unsetenv("XDG_CACHE_HOME")
+	api = oneandone.New("dummy", server.URL)
+	defer func() { api = nil }()
+
+	tests := []struct {
+		ref, serverType, expected string
+	}{
+		{"U1604", "cloud", "U1604"},
+		{"ubuntu:latest", "cloud", "U1804"},
+		{"ubuntu", "cloud", "ubuntu"},
+		{"Ubuntu:", "cloud", "U1804"},
+		{"ubuntu:latest:32", "cloud", "U1804-32"},
+		{"ubuntu:16", "cloud", "U1604"},
+		{"ubuntu:latest", "baremetal", "U1604"},
+		{"debian:latest", "cloud", "D10"},
+		{"debian:9:64", "cloud", "D9"},
+		{"windows:latest", "cloud", "W2016"},
+	}
+	for _, test := range tests {
+		if id := resolveApplianceId(test.ref, test.serverType); id != test.expected {
+			t.Errorf("%s on %s server: expected %s, got %s", test.ref, test.serverType, test.expected, id)
+		}
+	}
+}
+
+func TestPageRange(t *testing.T) {
+	tests := []struct {
+		page, perPage, n, from, to int
+	}{
+		{0, 0, 7, 0, 7},
+		{1, 3, 7, 0, 3},
+		{3, 3, 7, 6, 7},
+		{4, 3, 7, 7, 7},
+		{0, 5, 2, 0, 2},
+	}
+	for _, test := range tests {
+		if from, to := pageRange(test.page, test.perPage, test.n); from != test.from || to != test.to {
+			t.Errorf("page %d of %d of %d items: expected [%d:%d], got [%d:%d]",
+				test.page, test.perPage, test.n, test.from, test.to, from, to)
+		}
+	}
+}
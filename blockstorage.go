@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/1and1/oneandone-cloudserver-sdk-go"
 	"github.com/codegangsta/cli"
@@ -15,6 +16,15 @@ func init() {
 		Name:  "id, i",
 		Usage: "ID of the block storage drive.",
 	}
+	bsForceFlag := cli.BoolFlag{
+		Name:  "force",
+		Usage: "Detach the block storage even if the server is powered on.",
+	}
+	bsTimeoutFlag := cli.IntFlag{
+		Name:  "timeout",
+		Value: 300,
+		Usage: "Maximum time in seconds to wait for the block storage.",
+	}
 	blockStorageOps = []cli.Command{
 		{
 			Name:        "blockstorage",
@@ -75,6 +85,11 @@ func init() {
 							Name:  "serverid",
 							Usage: "ID of the server to which to attach the block storage.",
 						},
+						cli.BoolFlag{
+							Name:  "wait",
+							Usage: "Wait until the block storage is active on the server and show its disk ID and UUID.",
+						},
+						bsTimeoutFlag,
 					},
 					Action: attachBsDrive,
 				},
@@ -85,11 +100,36 @@ func init() {
 					Action: showBsDriveServer,
 				},
 				{
-					Name:   "detach",
-					Usage:  "Detaches a block storage from a server.",
-					Flags:  []cli.Flag{bsDriveIdFlag},
+					Name:  "detach",
+					Usage: "Detaches a block storage from a server.",
+					Flags: []cli.Flag{
+						bsDriveIdFlag,
+						cli.StringFlag{
+							Name:  "serverid",
+							Usage: "ID of the server from which to detach the block storage.",
+						},
+						cli.BoolFlag{
+							Name:  "safe",
+							Usage: "Refuse to detach while the server is powered on.",
+						},
+						bsForceFlag,
+					},
 					Action: detachBsDrive,
 				},
+				{
+					Name:  "move",
+					Usage: "Moves block storage to another server, attaching it back on failure.",
+					Flags: []cli.Flag{
+						bsDriveIdFlag,
+						cli.StringFlag{
+							Name:  "to-server",
+							Usage: "ID of the server to which to move the block storage.",
+						},
+						bsForceFlag,
+						bsTimeoutFlag,
+					},
+					Action: moveBsDrive,
+				},
 				{
 					Name:  "update",
 					Usage: "Updates block storage.",
@@ -182,7 +222,14 @@ func attachBsDrive(ctx *cli.Context) {
 
 	storage, err := api.AddBlockStorageServer(driveId, serverId)
 	exitOnError(err)
-	output(ctx, storage, okWaitMessage, false, nil, nil)
+	if !ctx.Bool("wait") {
+		output(ctx, storage, okWaitMessage, false, nil, nil)
+		return
+	}
+	timeout := time.Duration(validateIntRange("timeout", ctx.Int("timeout"), 1, 3600)) * time.Second
+	storage, err = waitForBsServer(driveId, serverId, timeout)
+	exitOnError(err)
+	outputBsMapping(ctx, storage, "")
 }
 
 func showBsDriveServer(ctx *cli.Context) {
@@ -195,6 +242,9 @@ func showBsDriveServer(ctx *cli.Context) {
 func detachBsDrive(ctx *cli.Context) {
 	driveId := getRequiredOption(ctx, "id")
 	serverId := getRequiredOption(ctx, "serverid")
+	if ctx.Bool("safe") {
+		verifyServerPoweredOff(serverId, ctx.Bool("force"))
+	}
 	storage, err := api.RemoveBlockStorageServer(driveId, serverId)
 	exitOnError(err)
 	output(ctx, storage, okWaitMessage, false, nil, nil)
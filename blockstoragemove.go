@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/1and1/oneandone-cloudserver-sdk-go"
+	"github.com/codegangsta/cli"
+)
+
+// Returns the ID of the server the block storage is attached to or an empty string.
+func getBsAttachedServer(storage *oneandone.BlockStorage) string {
+	if storage.Server == nil {
+		return ""
+	}
+	if storage.Server.ServerId != "" {
+		return storage.Server.ServerId
+	}
+	return storage.Server.Id
+}
+
+// Waits until the block storage is attached to the server, or detached from any server
+// if serverId is empty, and no operation is in progress on it.
+func waitForBsServer(driveId, serverId string, timeout time.Duration) (*oneandone.BlockStorage, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		storage, err := api.GetBlockStorage(driveId)
+		if err != nil {
+			return nil, err
+		}
+		if getBsAttachedServer(storage) == serverId && !strings.HasSuffix(storage.State, "ING") {
+			return storage, nil
+		}
+		if time.Now().After(deadline) {
+			if serverId == "" {
+				return storage, fmt.Errorf("timeout waiting for block storage %s to be detached", storage.Name)
+			}
+			return storage, fmt.Errorf("timeout waiting for block storage %s to be attached to server %s", storage.Name, serverId)
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+// Refuses to continue if the server is powered on, unless forced.
+func verifyServerPoweredOff(serverId string, force bool) {
+	if force {
+		return
+	}
+	status, err := api.GetServerStatus(serverId)
+	exitOnError(err)
+	if status.State != "POWERED_OFF" {
+		exitOnError(fmt.Errorf("server %s is %s, power it off first or use --force to detach anyway", serverId, status.State))
+	}
+}
+
+func outputBsMapping(ctx *cli.Context, storage *oneandone.BlockStorage, message string) {
+	serverName := getBsServer(storage.Server)
+	if storage.Server != nil && storage.Server.Name != "" {
+		serverName = storage.Server.Name
+	}
+	data := [][]string{{storage.Id, storage.Name, serverName, storage.DiskID, storage.UUID}}
+	header := []string{"ID", "Name", "Server", "Disk ID", "UUID"}
+	output(ctx, storage, message, false, &header, &data)
+}
+
+func moveBsDrive(ctx *cli.Context) {
+	driveId := getRequiredOption(ctx, "id")
+	targetId := getRequiredOption(ctx, "to-server")
+	timeout := time.Duration(validateIntRange("timeout", ctx.Int("timeout"), 1, 3600)) * time.Second
+
+	storage, err := api.GetBlockStorage(driveId)
+	exitOnError(err)
+	sourceId := getBsAttachedServer(storage)
+	if sourceId == targetId {
+		exitOnError(fmt.Errorf("block storage %s is already attached to server %s", storage.Name, targetId))
+	}
+
+	// Once the move has started, errors exit with status 1, so scripts can tell that it failed.
+	if sourceId != "" {
+		verifyServerPoweredOff(sourceId, ctx.Bool("force"))
+		fmt.Fprintf(os.Stderr, "Detaching block storage %s from server %s...\n", storage.Name, sourceId)
+		_, err = api.RemoveBlockStorageServer(driveId, sourceId)
+		exitOnErrorStatus(err, 1)
+		_, err = waitForBsServer(driveId, "", timeout)
+		exitOnErrorStatus(err, 1)
+	}
+
+	fmt.Fprintf(os.Stderr, "Attaching block storage %s to server %s...\n", storage.Name, targetId)
+	_, err = api.AddBlockStorageServer(driveId, targetId)
+	if err == nil {
+		storage, err = waitForBsServer(driveId, targetId, timeout)
+	}
+	if err != nil {
+		if sourceId == "" {
+			exitOnErrorStatus(err, 1)
+		}
+		fmt.Fprintf(os.Stderr, "Attaching failed: %v\nRolling back, attaching block storage back to server %s...\n", err, sourceId)
+		if _, rbErr := api.AddBlockStorageServer(driveId, sourceId); rbErr != nil {
+			exitOnErrorStatus(fmt.Errorf("rollback failed: %v", rbErr), 1)
+		}
+		_, rbErr := waitForBsServer(driveId, sourceId, timeout)
+		exitOnErrorStatus(rbErr, 1)
+		exitOnErrorStatus(fmt.Errorf("block storage was not moved and is attached to server %s again", sourceId), 1)
+	}
+	outputBsMapping(ctx, storage, "Block storage moved.\n")
+}
@@ -93,14 +93,35 @@ func TestRequiredArgs(t *testing.T) {
 		{"id", "log", "info"},
 		{"id", "monitor", "info"},
 		{"id", "monitorpolicy", "info"},
+		{"id", "monitorpolicy", "export"},
+		{"id", "role", "permissions", "export"},
+		{"id", "role", "permissions", "import"},
+		{"file", "role", "permissions", "import", "--id=dummy"},
+		{"id", "privatenet", "guestconfig"},
+		{"serverid", "privatenet", "guestconfig", "--id=dummy"},
+		{"format", "privatenet", "guestconfig", "--id=dummy", "--serverid=dummy"},
 		{"id", "privatenet", "info"},
 		{"id", "role", "info"},
 		{"id", "server", "info"},
 		{"id", "sharedstorage", "info"},
+		{"id", "sharedstorage", "mountconfig"},
+		{"serverid", "sharedstorage", "mountconfig", "--id=dummy"},
+		{"format", "sharedstorage", "mountconfig", "--id=dummy", "--serverid=dummy"},
 		{"id", "user", "info"},
 		{"id", "vpn", "info"},
 		{"id", "blockstorage", "info"},
+		{"id", "blockstorage", "move"},
+		{"file", "ip", "rdns", "sync"},
+		{"to-server", "blockstorage", "move", "--id=dummy"},
+		{"serverid", "blockstorage", "detach", "--id=dummy"},
 		{"id", "sshkey", "info"},
+		{"id", "firewall", "rulesfile", "export"},
+		{"file", "firewall", "rulesfile", "import"},
+		{"id", "firewall", "copy"},
+		{"id", "loadbalancer", "check"},
+		{"id", "loadbalancer", "export"},
+		{"file", "loadbalancer", "apply"},
+		{"name", "firewall", "copy", "--id=dummy"},
 		{"period", "usage", "images"},
 		{"period", "usage", "loadbalancers"},
 		{"period", "usage", "ips"},
@@ -130,6 +151,10 @@ func TestRequiredArgs(t *testing.T) {
 		{"name", "server", "create"},
 		{"name", "vpn", "create"},
 		{"osid", "server", "create", "--name=dummy"},
+		{"modelid", "server", "createbaremetal", "--name=dummy", "--osid=dummy"},
+		{"name", "server", "template", "rm"},
+		{"name", "server", "template", "save"},
+		{"name", "server", "template", "show"},
 	}
 	for _, op := range ops {
 		out, err := runCommand(appPath, op[1:len(op)]...)
@@ -169,6 +194,29 @@ func TestNoArgsCommandHelp(t *testing.T) {
 	}
 }
 
+func TestNestedCommandHelp(t *testing.T) {
+	ops := [][]string{
+		{"ip", "rdns", "--help"},
+		{"ip", "rdns", "list", "--help"},
+		{"ip", "rdns", "export", "--help"},
+		{"firewall", "rules", "--help"},
+		{"firewall", "rulesfile", "--help"},
+		{"firewall", "rulesfile", "export", "--help"},
+		{"firewall", "rulesfile", "import", "--help"},
+	}
+	for _, op := range ops {
+		out, err := runCommand(appPath, op...)
+		assertContain(t, err, out, []string{"Usage:"})
+	}
+}
+
+func TestTopLevelCommandHelp(t *testing.T) {
+	for _, cmd := range []string{"top", "inventory", "ssh", "scp", "login", "logout"} {
+		out, err := runCommand(appPath, cmd, "--help")
+		assertContain(t, err, out, []string{"Usage: " + appName + " " + cmd + " "})
+	}
+}
+
 func TestRequiredIntSlice(t *testing.T) {
 	ops := [][]string{
 		{"portfrom", "firewall", "create", "--name=dummy"},
@@ -187,6 +235,9 @@ func TestRequiredIntSlice(t *testing.T) {
 func TestRequiredStringSlice(t *testing.T) {
 	ops := [][]string{
 		{"ipid", "firewall", "assign", "--id=dummy"},
+		{"id", "firewall", "diff"},
+		{"id", "role", "diff"},
+		{"id", "role", "matrix"},
 		{"serverid", "privatenet", "assign", "--id=dummy"},
 		{"serverid", "sharedstorage", "attach", "--id=dummy"},
 		{"perm", "sharedstorage", "attach", "--id=dummy", "--serverid=dummy"},
@@ -209,6 +260,12 @@ func TestRequiredIntRange(t *testing.T) {
 	assertEqual(t, err, fmt.Sprintf(requiredIntRange, "size", 50, 2000), out)
 }
 
+func TestHddSize(t *testing.T) {
+	out, err := runCommand(appPath, "server", "hddadd", "--id=dummy", "--size=40", "--size=15", "--size=2500")
+	assertEqual(t, err, "--size must be between 20 and 2000 GB and a multiple of 10, got 15\n"+
+		"--size must be between 20 and 2000 GB and a multiple of 10, got 2500\n", out)
+}
+
 func TestPingResponse(t *testing.T) {
 	out, err := runCommand(appPath, "ping", "api")
 	assertEqual(t, err, pingResponse, out)
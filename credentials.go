@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/1and1/oneandone-cloudserver-sdk-go"
+	"github.com/codegangsta/cli"
+)
+
+var loginOps []cli.Command
+
+const (
+	keyringService     = "oneandone-cloudserver-cli"
+	credentialsFile    = "credentials.json"
+	passphraseEnvVar   = "ONEANDONE_PASSPHRASE"
+	passphraseKdfIters = 600000
+	// Longest time a decrypted API key can be kept with 'login --cache-minutes'.
+	maxCacheMinutes = 1440
+)
+
+func init() {
+	loginOps = []cli.Command{
+		{
+			Name:        "login",
+			HelpName:    appName + " login",
+			Description: "Stores the API key in the Secret Service keyring or, if no keyring is available, in a passphrase encrypted file",
+			Usage:       "Stores the API key.",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "apikey-stdin",
+					Usage: "Read the API key from the first line of standard input instead of prompting for it.",
+				},
+				cli.BoolFlag{
+					Name:  "file",
+					Usage: "Store the API key in the encrypted file even if a keyring is available.",
+				},
+				cli.IntFlag{
+					Name:  "cache-minutes",
+					Usage: "Keep the decrypted API key of the encrypted file in XDG_RUNTIME_DIR for the minutes, so the passphrase is not prompted for by every command. Not cached by default.",
+				},
+			},
+			Action: login,
+		},
+		{
+			Name:        "logout",
+			HelpName:    appName + " logout",
+			Description: "Removes the API key from the keyring and the encrypted file",
+			Usage:       "Removes the stored API key.",
+			Action:      logout,
+		},
+	}
+}
+
+// API key encrypted with AES-GCM using a key derived from a passphrase.
+type encryptedApiKey struct {
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+	// Minutes the decrypted key is cached for, it is not cached if zero.
+	CacheMinutes int `json:"cache_minutes,omitempty"`
+}
+
+func getBaseUrl(url string) string {
+	if url == "" {
+		return oneandone.BaseUrl
+	}
+	return url
+}
+
+// Runs secret-tool of libsecret to access the Secret Service keyring.
+func secretTool(stdin string, args ...string) (string, error) {
+	cmd := exec.Command("secret-tool", args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil && stderr.Len() > 0 {
+		err = fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), err
+}
+
+func keyringAvailable() bool {
+	_, err := exec.LookPath("secret-tool")
+	return err == nil && os.Getenv("DBUS_SESSION_BUS_ADDRESS") != ""
+}
+
+func getPassphrase(confirm bool) (string, error) {
+	if passphrase := os.Getenv(passphraseEnvVar); passphrase != "" {
+		return passphrase, nil
+	}
+	if !isTerminal(os.Stdin) {
+		return "", fmt.Errorf("cannot prompt for the passphrase of the stored API key, standard input is not a terminal; set %s or use --apikey", passphraseEnvVar)
+	}
+	passphrase := promptSecret("Passphrase of the stored API key: ")
+	if confirm && promptSecret("Repeat passphrase: ") != passphrase {
+		return "", fmt.Errorf("the entries do not match")
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf("the passphrase must not be empty")
+	}
+	return passphrase, nil
+}
+
+// Derives a key of keyLen bytes from the passphrase with PBKDF2-HMAC-SHA256 as of RFC 8018.
+func pbkdf2Key(passphrase string, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, []byte(passphrase))
+	var key []byte
+	for block := uint32(1); len(key) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.Write(prf, binary.BigEndian, block)
+		u := prf.Sum(nil)
+		t := append([]byte(nil), u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
+
+func newApiKeyCipher(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	key := pbkdf2Key(passphrase, salt, iterations, 32)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func encryptApiKey(apiKey, passphrase string) (*encryptedApiKey, error) {
+	enc := &encryptedApiKey{Iterations: passphraseKdfIters, Salt: make([]byte, 16)}
+	if _, err := rand.Read(enc.Salt); err != nil {
+		return nil, err
+	}
+	aead, err := newApiKeyCipher(passphrase, enc.Salt, enc.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	enc.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(enc.Nonce); err != nil {
+		return nil, err
+	}
+	enc.Ciphertext = aead.Seal(nil, enc.Nonce, []byte(apiKey), nil)
+	return enc, nil
+}
+
+func (enc *encryptedApiKey) decrypt(passphrase string) (string, error) {
+	aead, err := newApiKeyCipher(passphrase, enc.Salt, enc.Iterations)
+	if err != nil {
+		return "", err
+	}
+	apiKey, err := aead.Open(nil, enc.Nonce, enc.Ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt the stored API key, wrong passphrase?")
+	}
+	return string(apiKey), nil
+}
+
+// Reads the encrypted API keys stored in the credentials file by API base URL.
+func readCredentialsFile() (map[string]*encryptedApiKey, error) {
+	keys := make(map[string]*encryptedApiKey)
+	content, err := ioutil.ReadFile(filepath.Join(getConfigDir(), credentialsFile))
+	if os.IsNotExist(err) {
+		return keys, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return keys, json.Unmarshal(content, &keys)
+}
+
+func writeCredentialsFile(keys map[string]*encryptedApiKey) error {
+	fileName := filepath.Join(getConfigDir(), credentialsFile)
+	if len(keys) == 0 {
+		err := os.Remove(fileName)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if err := os.MkdirAll(getConfigDir(), 0700); err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName, content, 0600)
+}
+
+// Returns the file caching the decrypted API key of the base URL for the session. The file is kept
+// in XDG_RUNTIME_DIR only, which is private to the user and removed when the user logs out.
+func getSessionKeyFile(url string) string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		return ""
+	}
+	hash := sha256.Sum256([]byte(url))
+	return filepath.Join(dir, appName, "apikey-"+hex.EncodeToString(hash[:8]))
+}
+
+func readSessionKey(url string, ttl time.Duration) string {
+	fileName := getSessionKeyFile(url)
+	if fileName == "" {
+		return ""
+	}
+	if info, err := os.Stat(fileName); err != nil || time.Since(info.ModTime()) > ttl {
+		os.Remove(fileName)
+		return ""
+	}
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return ""
+	}
+	return string(content)
+}
+
+// Caches the API key for the session. Failing to cache the key is not an error.
+func writeSessionKey(url, apiKey string) {
+	if fileName := getSessionKeyFile(url); fileName != "" && os.MkdirAll(filepath.Dir(fileName), 0700) == nil {
+		ioutil.WriteFile(fileName, []byte(apiKey), 0600)
+	}
+}
+
+func removeSessionKey(url string) {
+	if fileName := getSessionKeyFile(url); fileName != "" {
+		os.Remove(fileName)
+	}
+}
+
+// Returns the API key stored for the API base URL by 'oneandone login', or an empty string if none is stored.
+func loadStoredApiKey(url string) (string, error) {
+	url = getBaseUrl(url)
+	if keyringAvailable() {
+		if apiKey, err := secretTool("", "lookup", "service", keyringService, "url", url); err == nil && apiKey != "" {
+			return strings.TrimRight(apiKey, "\n"), nil
+		}
+	}
+	keys, err := readCredentialsFile()
+	if err != nil {
+		return "", err
+	}
+	enc, ok := keys[url]
+	if !ok {
+		return "", nil
+	}
+	ttl := time.Duration(enc.CacheMinutes) * time.Minute
+	if apiKey := readSessionKey(url, ttl); apiKey != "" {
+		return apiKey, nil
+	}
+	passphrase, err := getPassphrase(false)
+	if err != nil {
+		return "", err
+	}
+	apiKey, err := enc.decrypt(passphrase)
+	if err == nil && ttl > 0 {
+		writeSessionKey(url, apiKey)
+	}
+	return apiKey, err
+}
+
+func login(ctx *cli.Context) {
+	url := getBaseUrl(ctx.GlobalString("baseurl"))
+	cacheMinutes := validateIntRange("cache-minutes", ctx.Int("cache-minutes"), 0, maxCacheMinutes)
+	var apiKey string
+	if ctx.Bool("apikey-stdin") {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			exitOnError(fmt.Errorf("failed to read the API key from standard input: %v", err))
+		}
+		apiKey = strings.TrimSpace(line)
+	} else if isTerminal(os.Stdin) {
+		apiKey = strings.TrimSpace(promptSecret("API key: "))
+	}
+	if apiKey == "" {
+		exitOnError(fmt.Errorf("no API key given, enter it at the prompt or use --apikey-stdin"))
+	}
+
+	client, err := newClient(apiKey, url)
+	exitOnError(err)
+	_, err = client.PingAuth()
+	exitOnError(err)
+
+	switch {
+	case ctx.Bool("file"):
+		fmt.Println("The API key will be stored in a passphrase encrypted file.")
+	case keyringAvailable():
+		_, err := secretTool(apiKey, "store", "--label=1&1 Cloud Server API key", "service", keyringService, "url", url)
+		if err == nil {
+			fmt.Println("The API key is valid and stored in the keyring.")
+			return
+		}
+		fmt.Fprintf(os.Stderr, "Storing the API key in the keyring failed: %v\n", err)
+		fmt.Println("The API key will be stored in a passphrase encrypted file instead.")
+	default:
+		fmt.Println("No keyring available, the API key will be stored in a passphrase encrypted file.")
+	}
+
+	passphrase, err := getPassphrase(true)
+	exitOnError(err)
+	enc, err := encryptApiKey(apiKey, passphrase)
+	exitOnError(err)
+	enc.CacheMinutes = cacheMinutes
+	keys, err := readCredentialsFile()
+	exitOnError(err)
+	keys[url] = enc
+	exitOnError(writeCredentialsFile(keys))
+	removeSessionKey(url)
+	fmt.Printf("The API key is valid and stored in %s.\n", filepath.Join(getConfigDir(), credentialsFile))
+}
+
+func logout(ctx *cli.Context) {
+	url := getBaseUrl(ctx.GlobalString("baseurl"))
+	removed := false
+	if keyringAvailable() {
+		if apiKey, err := secretTool("", "lookup", "service", keyringService, "url", url); err == nil && apiKey != "" {
+			_, err = secretTool("", "clear", "service", keyringService, "url", url)
+			exitOnError(err)
+			removed = true
+		}
+	}
+	removeSessionKey(url)
+	keys, err := readCredentialsFile()
+	exitOnError(err)
+	if _, ok := keys[url]; ok {
+		delete(keys, url)
+		exitOnError(writeCredentialsFile(keys))
+		removed = true
+	}
+	if removed {
+		fmt.Println("The stored API key is removed.")
+	} else {
+		fmt.Println("No API key is stored.")
+	}
+}
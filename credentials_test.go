@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestPbkdf2Key(t *testing.T) {
+	// Test vectors of PBKDF2-HMAC-SHA256 from RFC 7914.
+	tests := []struct {
+		passphrase, salt string
+		iterations       int
+		key              string
+	}{
+		{"passwd", "salt", 1, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" +
+			"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+		{"Password", "NaCl", 80000, "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56" +
+			"a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d"},
+	}
+	for _, test := range tests {
+		key := hex.EncodeToString(pbkdf2Key(test.passphrase, []byte(test.salt), test.iterations, 64))
+		if key != test.key {
+			t.Errorf("%s/%s/%d: expected key %s, got %s", test.passphrase, test.salt, test.iterations, test.key, key)
+		}
+	}
+}
+
+func TestApiKeyEncryption(t *testing.T) {
+	enc, err := encryptApiKey("secret-key", "passphrase")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if apiKey, err := enc.decrypt("passphrase"); err != nil || apiKey != "secret-key" {
+		t.Errorf("expected decrypted key 'secret-key', got '%s', %v", apiKey, err)
+	}
+	if _, err := enc.decrypt("wrong"); err == nil {
+		t.Errorf("expected decryption with a wrong passphrase to fail")
+	}
+}
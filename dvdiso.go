@@ -1,8 +1,7 @@
 package main
 
 import (
-	"strconv"
-
+	"github.com/1and1/oneandone-cloudserver-sdk-go"
 	"github.com/codegangsta/cli"
 )
 
@@ -29,7 +28,7 @@ func init() {
 				{
 					Name:   "list",
 					Usage:  "Lists all available DVD ISOs.",
-					Flags:  queryFlags,
+					Flags:  append(queryFlags, osFilterFlags...),
 					Action: listDvds,
 				},
 			},
@@ -38,18 +37,27 @@ func init() {
 }
 
 func listDvds(ctx *cli.Context) {
-	dvds, err := api.ListDvdIsos(getQueryParams(ctx))
-	exitOnError(err)
+	filtered, page, perPage, sort, query, fields := getFilteredQueryParams(ctx, osFilterFlags)
+	var dvds []oneandone.DvdIso
+	var err error
+	if filtered {
+		dvds, err = api.ListDvdIsos(0, 0, sort, query, "")
+		exitOnError(err)
+		matching := []oneandone.DvdIso{}
+		for i := range dvds {
+			if dvdIsoImage(&dvds[i]).matches(ctx) {
+				matching = append(matching, dvds[i])
+			}
+		}
+		from, to := pageRange(page, perPage, len(matching))
+		dvds = matching[from:to]
+	} else {
+		dvds, err = api.ListDvdIsos(page, perPage, sort, query, fields)
+		exitOnError(err)
+	}
 	data := make([][]string, len(dvds))
 	for i, dvd := range dvds {
-		var arch string
-		ar, isNum := dvd.Architecture.(float64)
-		if isNum {
-			arch = strconv.FormatFloat(ar, 'f', -1, 64)
-		} else {
-			arch, _ = dvd.Architecture.(string)
-		}
-		data[i] = []string{dvd.Id, dvd.Name, dvd.OsVersion, arch}
+		data[i] = []string{dvd.Id, dvd.Name, dvd.OsVersion, formatArchitecture(dvd.Architecture)}
 	}
 	header := []string{"ID", "Name", "OS", "Architecture"}
 	output(ctx, dvds, "", false, &header, &data)
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/1and1/oneandone-cloudserver-sdk-go"
+	"github.com/codegangsta/cli"
+)
+
+const (
+	severityLow = iota + 1
+	severityMedium
+	severityHigh
+)
+
+// Exit status when the policies cannot be checked, findings at --failon exit with 1.
+const lintErrorStatus = 2
+
+// Ports that should never be reachable from any source.
+var sensitivePorts = []struct {
+	port    int
+	service string
+}{
+	{22, "SSH"},
+	{23, "Telnet"},
+	{445, "SMB"},
+	{1433, "MS SQL"},
+	{1521, "Oracle"},
+	{2375, "Docker"},
+	{3306, "MySQL"},
+	{3389, "RDP"},
+	{5432, "PostgreSQL"},
+	{5900, "VNC"},
+	{5984, "CouchDB"},
+	{6379, "Redis"},
+	{9200, "Elasticsearch"},
+	{11211, "Memcached"},
+	{27017, "MongoDB"},
+}
+
+type firewallFinding struct {
+	Severity int    `json:"-"`
+	Level    string `json:"severity"`
+	PolicyId string `json:"policy_id,omitempty"`
+	Policy   string `json:"policy,omitempty"`
+	Rule     string `json:"rule,omitempty"`
+	Issue    string `json:"issue"`
+}
+
+func severityName(severity int) string {
+	switch severity {
+	case severityHigh:
+		return "HIGH"
+	case severityMedium:
+		return "MEDIUM"
+	case severityLow:
+		return "LOW"
+	}
+	return "NONE"
+}
+
+func parseSeverity(flag, value string) int {
+	switch strings.ToLower(value) {
+	case "high":
+		return severityHigh
+	case "medium":
+		return severityMedium
+	case "low":
+		return severityLow
+	case "none":
+		return 0
+	}
+	exitOnError(fmt.Errorf("--%s must be either high, medium, low or none", flag))
+	return 0
+}
+
+func lintFirewalls(ctx *cli.Context) {
+	failOn := parseSeverity("failon", ctx.String("failon"))
+
+	var policies []oneandone.FirewallPolicy
+	ids := getStringSliceOption(ctx, "id", false)
+	if len(ids) > 0 {
+		for _, id := range ids {
+			policy, err := api.GetFirewallPolicy(id)
+			exitOnErrorStatus(err, lintErrorStatus)
+			policies = append(policies, *policy)
+		}
+	} else {
+		var err error
+		policies, err = api.ListFirewallPolicies()
+		exitOnErrorStatus(err, lintErrorStatus)
+	}
+
+	var findings []firewallFinding
+	for i := range policies {
+		findings = append(findings, lintFirewallPolicy(&policies[i])...)
+	}
+	// Unprotected IPs can only be detected when all policies are known.
+	if len(ids) == 0 {
+		unprotected, err := findUnprotectedIps(policies)
+		exitOnErrorStatus(err, lintErrorStatus)
+		findings = append(findings, unprotected...)
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Severity > findings[j].Severity
+	})
+	data := make([][]string, len(findings))
+	worst := 0
+	for i := range findings {
+		findings[i].Level = severityName(findings[i].Severity)
+		data[i] = []string{findings[i].Level, findings[i].Policy, findings[i].Rule, findings[i].Issue}
+		if findings[i].Severity > worst {
+			worst = findings[i].Severity
+		}
+	}
+	header := []string{"Severity", "Policy", "Rule", "Issue"}
+	message := fmt.Sprintf("%d issue(s) found in %d firewall policies.\n", len(findings), len(policies))
+	output(ctx, findings, message, false, &header, &data)
+
+	if failOn > 0 && worst >= failOn {
+		os.Exit(1)
+	}
+}
+
+func lintFirewallPolicy(policy *oneandone.FirewallPolicy) []firewallFinding {
+	var findings []firewallFinding
+	add := func(severity int, rule *firewallRuleRecord, issue string) {
+		finding := firewallFinding{
+			Severity: severity,
+			PolicyId: policy.Id,
+			Policy:   policy.Name,
+			Issue:    issue,
+		}
+		if rule != nil {
+			finding.Rule = strings.Join(strings.Fields(rule.Protocol+" "+rule.ports()+" from "+rule.Source), " ")
+		}
+		findings = append(findings, finding)
+	}
+
+	if len(policy.Rules) == 0 {
+		add(severityLow, nil, "policy has no rules")
+	}
+
+	rules := make([]firewallRuleRecord, len(policy.Rules))
+	for i := range policy.Rules {
+		rules[i] = newFirewallRuleRecord(&policy.Rules[i])
+	}
+
+	for i := range rules {
+		r := &rules[i]
+		if r.Protocol == "ANY" {
+			if r.Action == "deny" {
+				add(severityMedium, r, "ANY rule denies all traffic from the source, review if intended")
+			} else if isAnySource(r.Source) {
+				add(severityHigh, r, "all traffic is open to the internet")
+			}
+			continue
+		}
+		if r.Action != "allow" || !r.hasPorts() {
+			continue
+		}
+		public := isAnySource(r.Source)
+		if public && r.PortFrom <= 1 && r.PortTo >= 65535 {
+			add(severityHigh, r, "all ports are open to the internet")
+			continue
+		}
+		if public {
+			for _, p := range sensitivePorts {
+				if p.port >= r.PortFrom && p.port <= r.PortTo {
+					add(severityHigh, r, fmt.Sprintf("%s port %d is open to the internet", p.service, p.port))
+				}
+			}
+			if r.PortTo-r.PortFrom >= 1000 {
+				add(severityMedium, r, fmt.Sprintf("wide range of %d ports is open to the internet", r.PortTo-r.PortFrom+1))
+			}
+		}
+
+		for j := 0; j < i; j++ {
+			o := &rules[j]
+			if o.Action != r.Action || !o.hasPorts() || !protocolsOverlap(o.Protocol, r.Protocol) {
+				continue
+			}
+			if o.key() == r.key() {
+				add(severityLow, r, fmt.Sprintf("duplicates rule %d", j+1))
+			} else if coversSource(o.Source, r.Source) && coversProtocol(o.Protocol, r.Protocol) &&
+				o.PortFrom <= r.PortFrom && o.PortTo >= r.PortTo {
+				add(severityLow, r, fmt.Sprintf("shadowed by rule %d (%s %s from %s)", j+1, o.Protocol, o.ports(), o.Source))
+			} else if o.Source == r.Source && o.PortFrom <= r.PortTo && r.PortFrom <= o.PortTo {
+				add(severityLow, r, fmt.Sprintf("port range overlaps rule %d (%s %s)", j+1, o.Protocol, o.ports()))
+			}
+		}
+	}
+	return findings
+}
+
+// Reports server IPs that are not assigned to any of the given firewall policies.
+func findUnprotectedIps(policies []oneandone.FirewallPolicy) ([]firewallFinding, error) {
+	protected := make(map[string]bool)
+	for _, policy := range policies {
+		ips, err := api.ListFirewallPolicyServerIps(policy.Id)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			protected[ip.Id] = true
+		}
+	}
+	servers, err := api.ListServers()
+	if err != nil {
+		return nil, err
+	}
+	var findings []firewallFinding
+	for _, server := range servers {
+		for _, ip := range server.Ips {
+			if !protected[ip.Id] {
+				findings = append(findings, firewallFinding{
+					Severity: severityHigh,
+					Issue:    fmt.Sprintf("IP %s of server '%s' has no firewall policy", ip.Ip, server.Name),
+				})
+			}
+		}
+	}
+	return findings, nil
+}
+
+func isAnySource(source string) bool {
+	return source == anySource || source == "0.0.0.0/0" || source == "" || source == "::/0"
+}
+
+// Tells whether the source of one rule includes the source of another one.
+// Only exact matches and the any-source are compared, arbitrary CIDRs are not.
+func coversSource(outer, inner string) bool {
+	return outer == inner || isAnySource(outer)
+}
+
+func coversProtocol(outer, inner string) bool {
+	return outer == inner || outer == "TCP/UDP"
+}
+
+func protocolsOverlap(a, b string) bool {
+	return a == b || a == "TCP/UDP" || b == "TCP/UDP"
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/1and1/oneandone-cloudserver-sdk-go"
+)
+
+func TestLintFirewallPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    []firewallRuleRecord
+		expected []string
+	}{
+		{"empty", nil, []string{"LOW||policy has no rules"}},
+		{
+			"sensitive ports",
+			[]firewallRuleRecord{
+				{Protocol: "TCP", PortFrom: 22, PortTo: 22, Source: anySource, Action: "allow"},
+				{Protocol: "TCP", PortFrom: 3306, PortTo: 3306, Source: "10.0.0.0/8", Action: "allow"},
+			},
+			[]string{"HIGH|TCP 22 from 0.0.0.0|SSH port 22 is open to the internet"},
+		},
+		{
+			"all ports",
+			[]firewallRuleRecord{{Protocol: "TCP", PortFrom: 1, PortTo: 65535, Source: anySource, Action: "allow"}},
+			[]string{"HIGH|TCP 1-65535 from 0.0.0.0|all ports are open to the internet"},
+		},
+		{
+			"duplicate and shadowed",
+			[]firewallRuleRecord{
+				{Protocol: "TCP/UDP", PortFrom: 8000, PortTo: 8100, Source: "10.0.0.1", Action: "allow"},
+				{Protocol: "TCP/UDP", PortFrom: 8000, PortTo: 8100, Source: "10.0.0.1", Action: "allow"},
+				{Protocol: "TCP", PortFrom: 8080, PortTo: 8080, Source: "10.0.0.1", Action: "allow"},
+			},
+			[]string{
+				"LOW|TCP/UDP 8000-8100 from 10.0.0.1|duplicates rule 1",
+				"LOW|TCP 8080 from 10.0.0.1|shadowed by rule 1 (TCP/UDP 8000-8100 from 10.0.0.1)",
+				"LOW|TCP 8080 from 10.0.0.1|shadowed by rule 2 (TCP/UDP 8000-8100 from 10.0.0.1)",
+			},
+		},
+		{
+			"ANY rules",
+			[]firewallRuleRecord{
+				{Protocol: "ANY", Source: "192.168.1.1", Action: "deny"},
+				{Protocol: "ANY", Source: "10.0.0.1", Action: "allow"},
+				{Protocol: "ANY", Source: anySource, Action: "allow"},
+			},
+			[]string{
+				"MEDIUM|ANY from 192.168.1.1|ANY rule denies all traffic from the source, review if intended",
+				"HIGH|ANY from 0.0.0.0|all traffic is open to the internet",
+			},
+		},
+	}
+	for _, test := range tests {
+		policy := &oneandone.FirewallPolicy{Name: test.name}
+		for _, r := range test.rules {
+			policy.Rules = append(policy.Rules, r.request())
+		}
+		var got []string
+		for _, f := range lintFirewallPolicy(policy) {
+			got = append(got, severityName(f.Severity)+"|"+f.Rule+"|"+f.Issue)
+		}
+		if !reflect.DeepEqual(test.expected, got) {
+			t.Errorf("%s: expected findings %q, got %q", test.name, test.expected, got)
+		}
+	}
+}
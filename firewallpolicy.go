@@ -63,6 +63,14 @@ func init() {
 		Name:  "ruleid",
 		Usage: "ID of the rule.",
 	}
+	fFileFlag := cli.StringFlag{
+		Name:  "file, f",
+		Usage: "Path to the rule file.",
+	}
+	fFormatFlag := cli.StringFlag{
+		Name:  "format",
+		Usage: "Rule file format: " + firewallFormats + ". Determined by the file extension if not set.",
+	}
 
 	fCreateFlags := []cli.Flag{fDescFlag, fNameFlag}
 	fCreateFlags = append(fCreateFlags, fRuleFlags...)
@@ -91,12 +99,53 @@ func init() {
 					Flags:  fCreateFlags,
 					Action: createFirewall,
 				},
+				{
+					Name:  "copy",
+					Usage: "Creates new firewall policy with the rules of an existing one.",
+					Flags: []cli.Flag{
+						fIdFlag,
+						fNameFlag,
+						fDescFlag,
+						cli.BoolFlag{
+							Name:  "assignips",
+							Usage: "Move server IPs assigned to the source policy to the new policy.",
+						},
+					},
+					Action: copyFirewall,
+				},
+				{
+					Name:  "diff",
+					Usage: "Shows rule differences between two firewall policies.",
+					Flags: []cli.Flag{
+						cli.StringSliceFlag{
+							Name:  "id, i",
+							Usage: "IDs of the two firewall policies to compare.",
+						},
+					},
+					Action: diffFirewalls,
+				},
 				{
 					Name:   "info",
 					Usage:  "Shows information about firewall policy.",
 					Flags:  []cli.Flag{fIdFlag},
 					Action: showFirewall,
 				},
+				{
+					Name:  "lint",
+					Usage: "Checks firewall policies for risky rules and unprotected server IPs.",
+					Flags: []cli.Flag{
+						cli.StringSliceFlag{
+							Name:  "id, i",
+							Usage: "List of firewall policy IDs. All policies are checked if not set.",
+						},
+						cli.StringFlag{
+							Name:  "failon",
+							Value: "high",
+							Usage: "Exit with non-zero status if an issue of this or higher severity is found: high, medium, low or none.",
+						},
+					},
+					Action: lintFirewalls,
+				},
 				{
 					Name:   "list",
 					Usage:  "Lists available firewall policies.",
@@ -127,6 +176,37 @@ func init() {
 					Flags:  []cli.Flag{fIdFlag},
 					Action: listFirewallRules,
 				},
+				{
+					Name:  "rulesfile",
+					Usage: "Exports and imports firewall policy rules in CSV, YAML, iptables or UFW format.",
+					Subcommands: []cli.Command{
+						{
+							Name:   "export",
+							Usage:  "Exports firewall policy rules in CSV, YAML, iptables or UFW format.",
+							Flags:  []cli.Flag{fIdFlag, fFileFlag, fFormatFlag},
+							Action: exportFirewallRules,
+						},
+						{
+							Name:  "import",
+							Usage: "Creates firewall policy from a rule file or syncs existing policy with it.",
+							Flags: []cli.Flag{
+								cli.StringFlag{
+									Name:  "id, i",
+									Usage: "ID of the firewall policy to sync. A new policy is created if not set.",
+								},
+								fFileFlag,
+								fFormatFlag,
+								fDescFlag,
+								fNameFlag,
+								cli.BoolFlag{
+									Name:  "dryrun",
+									Usage: "Only show the changes needed to sync the policy.",
+								},
+							},
+							Action: importFirewallRules,
+						},
+					},
+				},
 				{
 					Name:   "server",
 					Usage:  "Shows information about server attached to firewall policy.",
@@ -365,3 +445,122 @@ func removeFirewallRule(ctx *cli.Context) {
 	exitOnError(err)
 	output(ctx, firewall, okWaitMessage, false, nil, nil)
 }
+
+// Rule found in both policies with different descriptions.
+type firewallRuleDiff struct {
+	Rule firewallRuleRecord `json:"rule"`
+	From string             `json:"from"`
+	To   string             `json:"to"`
+}
+
+func diffFirewalls(ctx *cli.Context) {
+	ids := getStringSliceOption(ctx, "id", true)
+	if len(ids) != 2 {
+		exitOnError(fmt.Errorf("exactly two --id options must be specified"))
+	}
+	first, err := api.GetFirewallPolicy(ids[0])
+	exitOnError(err)
+	second, err := api.GetFirewallPolicy(ids[1])
+	exitOnError(err)
+
+	diff := struct {
+		Name        []string             `json:"name,omitempty"`
+		Description []string             `json:"description,omitempty"`
+		Removed     []firewallRuleRecord `json:"only_in_first"`
+		Added       []firewallRuleRecord `json:"only_in_second"`
+		Changed     []firewallRuleDiff   `json:"description_changed"`
+	}{}
+	if first.Name != second.Name {
+		diff.Name = []string{first.Name, second.Name}
+	}
+	if first.Description != second.Description {
+		diff.Description = []string{first.Description, second.Description}
+	}
+
+	// Rules are matched by protocol, ports, source and action.
+	remaining := make(map[string][]firewallRuleRecord)
+	for i := range second.Rules {
+		record := newFirewallRuleRecord(&second.Rules[i])
+		remaining[record.key()] = append(remaining[record.key()], record)
+	}
+	for i := range first.Rules {
+		record := newFirewallRuleRecord(&first.Rules[i])
+		matches := remaining[record.key()]
+		if len(matches) == 0 {
+			diff.Removed = append(diff.Removed, record)
+			continue
+		}
+		if matches[0].Description != record.Description {
+			diff.Changed = append(diff.Changed, firewallRuleDiff{record, record.Description, matches[0].Description})
+		}
+		remaining[record.key()] = matches[1:]
+	}
+	for i := range second.Rules {
+		record := newFirewallRuleRecord(&second.Rules[i])
+		if len(remaining[record.key()]) > 0 {
+			diff.Added = append(diff.Added, record)
+			remaining[record.key()] = remaining[record.key()][1:]
+		}
+	}
+
+	var data [][]string
+	if diff.Name != nil {
+		data = append(data, []string{"~", "Name: " + first.Name + " -> " + second.Name, "", "", ""})
+	}
+	if diff.Description != nil {
+		data = append(data, []string{"~", "Description: " + first.Description + " -> " + second.Description, "", "", ""})
+	}
+	for _, r := range diff.Removed {
+		data = append(data, []string{"-", r.Protocol, r.ports(), r.Source, r.Action})
+	}
+	for _, r := range diff.Added {
+		data = append(data, []string{"+", r.Protocol, r.ports(), r.Source, r.Action})
+	}
+	for _, c := range diff.Changed {
+		r := c.Rule
+		data = append(data, []string{"~", r.Protocol, r.ports(), r.Source,
+			r.Action + " (description: " + c.From + " -> " + c.To + ")"})
+	}
+	message := "Firewall policies have the same rules.\n"
+	if len(data) > 0 {
+		message = fmt.Sprintf("- only in %s, + only in %s, ~ changed\n", first.Name, second.Name)
+	}
+	header := []string{"", "Protocol", "Ports", "Source", "Action"}
+	output(ctx, diff, message, false, &header, &data)
+}
+
+func copyFirewall(ctx *cli.Context) {
+	fwId := getRequiredOption(ctx, "id")
+	name := getRequiredOption(ctx, "name")
+	source, err := api.GetFirewallPolicy(fwId)
+	exitOnError(err)
+
+	req := oneandone.FirewallPolicyRequest{
+		Name:        name,
+		Description: source.Description,
+	}
+	if ctx.IsSet("desc") {
+		req.Description = ctx.String("desc")
+	}
+	for i := range source.Rules {
+		record := newFirewallRuleRecord(&source.Rules[i])
+		req.Rules = append(req.Rules, record.request())
+	}
+	_, firewall, err := api.CreateFirewallPolicy(&req)
+	exitOnError(err)
+
+	if ctx.Bool("assignips") {
+		ips, err := api.ListFirewallPolicyServerIps(fwId)
+		exitOnError(err)
+		if len(ips) > 0 {
+			ipIds := make([]string, len(ips))
+			for i, ip := range ips {
+				ipIds[i] = ip.Id
+			}
+			exitOnError(api.WaitForState(firewall, "ACTIVE", 5, 60))
+			firewall, err = api.AddFirewallPolicyServerIps(firewall.Id, ipIds)
+			exitOnError(err)
+		}
+	}
+	output(ctx, firewall, okWaitMessage, false, nil, nil)
+}
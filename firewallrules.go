@@ -0,0 +1,824 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/1and1/oneandone-cloudserver-sdk-go"
+	"github.com/codegangsta/cli"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	anySource       = "0.0.0.0"
+	firewallFormats = "csv, yaml, iptables or ufw"
+)
+
+var csvRuleHeader = []string{"protocol", "port_from", "port_to", "source", "action", "description"}
+
+// Firewall policy as it is written to and read from a rule file.
+type firewallPolicyFile struct {
+	Name        string               `yaml:"name,omitempty"`
+	Description string               `yaml:"description,omitempty"`
+	Rules       []firewallRuleRecord `yaml:"rules"`
+}
+
+// Firewall rule with normalized port range, source and action.
+type firewallRuleRecord struct {
+	Protocol    string `yaml:"protocol" json:"protocol"`
+	PortFrom    int    `yaml:"port_from,omitempty" json:"port_from,omitempty"`
+	PortTo      int    `yaml:"port_to,omitempty" json:"port_to,omitempty"`
+	Source      string `yaml:"source,omitempty" json:"source,omitempty"`
+	Action      string `yaml:"action,omitempty" json:"action,omitempty"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+}
+
+func newFirewallRuleRecord(rule *oneandone.FirewallPolicyRule) firewallRuleRecord {
+	record := firewallRuleRecord{
+		Protocol:    strings.ToUpper(rule.Protocol),
+		Source:      rule.SourceIp,
+		Action:      rule.Action,
+		Description: rule.Description,
+	}
+	if rule.PortFrom != nil && rule.PortTo != nil {
+		record.PortFrom = *rule.PortFrom
+		record.PortTo = *rule.PortTo
+	} else if rule.Port != "" {
+		record.PortFrom, record.PortTo, _ = parsePortRange(rule.Port, "-")
+	}
+	record.normalize()
+	return record
+}
+
+func (r *firewallRuleRecord) normalize() {
+	r.Protocol = strings.ToUpper(r.Protocol)
+	r.Action = strings.ToLower(r.Action)
+	if r.Action == "" {
+		r.Action = "allow"
+	}
+	if r.Source == "" || r.Source == "0.0.0.0/0" || r.Source == "any" {
+		r.Source = anySource
+	}
+	if r.PortTo == 0 {
+		r.PortTo = r.PortFrom
+	}
+}
+
+func (r *firewallRuleRecord) hasPorts() bool {
+	return r.Protocol == "TCP" || r.Protocol == "UDP" || r.Protocol == "TCP/UDP"
+}
+
+// Returns the fields that identify the rule. Descriptions are not part of the key.
+func (r *firewallRuleRecord) key() string {
+	return fmt.Sprintf("%s|%d|%d|%s|%s", r.Protocol, r.PortFrom, r.PortTo, r.Source, r.Action)
+}
+
+func (r *firewallRuleRecord) ports() string {
+	if !r.hasPorts() {
+		return ""
+	}
+	if r.PortFrom == r.PortTo {
+		return strconv.Itoa(r.PortFrom)
+	}
+	return fmt.Sprintf("%d-%d", r.PortFrom, r.PortTo)
+}
+
+func (r *firewallRuleRecord) validate() error {
+	switch r.Protocol {
+	case "TCP", "UDP", "TCP/UDP", "ICMP", "IPSEC", "GRE":
+		break
+	case "ANY":
+		if r.Action != "deny" {
+			return fmt.Errorf("protocol ANY is only allowed when the required action is deny")
+		}
+	default:
+		return fmt.Errorf("invalid protocol '%s'. Valid values are TCP, UDP, TCP/UDP, ICMP, IPSEC, GRE or ANY", r.Protocol)
+	}
+	if r.Action != "allow" && r.Action != "deny" {
+		return fmt.Errorf("invalid action '%s'. Valid values are allow or deny", r.Action)
+	}
+	if r.hasPorts() {
+		if r.PortFrom < 1 || r.PortFrom > 65535 || r.PortTo < 1 || r.PortTo > 65535 {
+			return fmt.Errorf("ports of %s rule must be in range [1 65535]", r.Protocol)
+		}
+		if r.PortFrom > r.PortTo {
+			return fmt.Errorf("first port %d is greater than second port %d", r.PortFrom, r.PortTo)
+		}
+	}
+	return nil
+}
+
+func (r *firewallRuleRecord) request() oneandone.FirewallPolicyRule {
+	rule := oneandone.FirewallPolicyRule{
+		Protocol:    r.Protocol,
+		SourceIp:    r.Source,
+		Action:      r.Action,
+		Description: r.Description,
+	}
+	if r.hasPorts() {
+		rule.PortFrom = oneandone.Int2Pointer(r.PortFrom)
+		rule.PortTo = oneandone.Int2Pointer(r.PortTo)
+	}
+	return rule
+}
+
+func parsePortRange(ports, sep string) (int, int, error) {
+	parts := strings.SplitN(ports, sep, 2)
+	from, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid port '%s'", ports)
+	}
+	to := from
+	if len(parts) == 2 {
+		to, err = strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid port range '%s'", ports)
+		}
+	}
+	return from, to, nil
+}
+
+// Determines the rule file format from --format option or the file extension.
+func getFirewallFormat(ctx *cli.Context, fileName string) string {
+	format := strings.ToLower(ctx.String("format"))
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(fileName)) {
+		case ".csv":
+			format = "csv"
+		case ".yaml", ".yml":
+			format = "yaml"
+		case ".rules", ".iptables":
+			format = "iptables"
+		case ".ufw":
+			format = "ufw"
+		}
+	}
+	switch format {
+	case "csv", "yaml", "iptables", "ufw":
+		return format
+	case "yml":
+		return "yaml"
+	case "":
+		exitOnError(fmt.Errorf("--format option is required"))
+	default:
+		exitOnError(fmt.Errorf("--format must be either %s", firewallFormats))
+	}
+	return format
+}
+
+func exportFirewallRules(ctx *cli.Context) {
+	fwId := getRequiredOption(ctx, "id")
+	fileName := ctx.String("file")
+	format := getFirewallFormat(ctx, fileName)
+
+	firewall, err := api.GetFirewallPolicy(fwId)
+	exitOnError(err)
+
+	policy := firewallPolicyFile{
+		Name:        firewall.Name,
+		Description: firewall.Description,
+	}
+	for i := range firewall.Rules {
+		policy.Rules = append(policy.Rules, newFirewallRuleRecord(&firewall.Rules[i]))
+	}
+
+	var buf bytes.Buffer
+	switch format {
+	case "csv":
+		err = writeFirewallCsv(&buf, &policy)
+	case "yaml":
+		err = yaml.NewEncoder(&buf).Encode(&policy)
+	case "iptables":
+		writeFirewallIptables(&buf, &policy)
+	case "ufw":
+		writeFirewallUfw(&buf, &policy)
+	}
+	exitOnError(err)
+
+	if fileName == "" {
+		fmt.Print(buf.String())
+		return
+	}
+	err = ioutil.WriteFile(fileName, buf.Bytes(), 0644)
+	exitOnError(err)
+	fmt.Printf("Firewall policy rules exported to %s\n", fileName)
+}
+
+func importFirewallRules(ctx *cli.Context) {
+	fileName := getRequiredOption(ctx, "file")
+	format := getFirewallFormat(ctx, fileName)
+
+	policy, err := readFirewallPolicyFile(fileName, format)
+	exitOnError(err)
+	if len(policy.Rules) == 0 {
+		exitOnError(fmt.Errorf("no firewall rules found in %s", fileName))
+	}
+	for i := range policy.Rules {
+		policy.Rules[i].normalize()
+		if err := policy.Rules[i].validate(); err != nil {
+			exitOnError(fmt.Errorf("rule %d: %s", i+1, err.Error()))
+		}
+	}
+
+	if ctx.IsSet("id") {
+		syncFirewallRules(ctx, ctx.String("id"), policy.Rules)
+		return
+	}
+
+	name := ctx.String("name")
+	if name == "" {
+		name = policy.Name
+	}
+	if strings.TrimSpace(name) == "" {
+		exitOnError(fmt.Errorf("--name option is required"))
+	}
+	desc := ctx.String("desc")
+	if desc == "" {
+		desc = policy.Description
+	}
+	req := oneandone.FirewallPolicyRequest{
+		Name:        name,
+		Description: desc,
+	}
+	for i := range policy.Rules {
+		req.Rules = append(req.Rules, policy.Rules[i].request())
+	}
+	_, firewall, err := api.CreateFirewallPolicy(&req)
+	exitOnError(err)
+	output(ctx, firewall, okWaitMessage, false, nil, nil)
+}
+
+// Adds rules that are missing in the policy and removes the ones not found in the desired rules.
+// Rules that only differ in their description are replaced, since rules cannot be modified.
+func syncFirewallRules(ctx *cli.Context, fwId string, desired []firewallRuleRecord) {
+	firewall, err := api.GetFirewallPolicy(fwId)
+	exitOnError(err)
+
+	missing, changed, extra := diffFirewallRules(firewall.Rules, desired)
+	if len(missing) == 0 && len(changed) == 0 && len(extra) == 0 {
+		fmt.Println("Firewall policy rules are up to date.")
+		return
+	}
+
+	changes := struct {
+		Added   []oneandone.FirewallPolicyRule `json:"added"`
+		Changed []firewallRuleChange           `json:"changed"`
+		Removed []oneandone.FirewallPolicyRule `json:"removed"`
+	}{missing, changed, extra}
+	var data [][]string
+	for _, rule := range missing {
+		data = append(data, firewallRuleChangeRow("+", &rule))
+	}
+	for _, c := range changed {
+		row := firewallRuleChangeRow("~", &c.To)
+		row[len(row)-1] = fmt.Sprintf("%s -> %s", c.From.Description, c.To.Description)
+		data = append(data, row)
+	}
+	for _, rule := range extra {
+		data = append(data, firewallRuleChangeRow("-", &rule))
+	}
+	header := []string{"Change", "Protocol", "Ports", "Source", "Action", "Description"}
+	output(ctx, changes, "", false, &header, &data)
+
+	if ctx.Bool("dryrun") {
+		return
+	}
+
+	for _, c := range changed {
+		missing = append(missing, c.To)
+		extra = append(extra, c.From)
+	}
+	if len(missing) > 0 {
+		exitOnError(api.WaitForState(firewall, "ACTIVE", 5, 60))
+		_, err = api.AddFirewallPolicyRules(fwId, missing)
+		exitOnError(err)
+	}
+	for _, rule := range extra {
+		exitOnError(api.WaitForState(firewall, "ACTIVE", 5, 60))
+		_, err = api.DeleteFirewallPolicyRule(fwId, rule.Id)
+		exitOnError(err)
+	}
+	fmt.Printf("%d rule(s) added, %d rule(s) changed, %d rule(s) removed.\n",
+		len(missing)-len(changed), len(changed), len(extra)-len(changed))
+	fmt.Print(okWaitMessage)
+}
+
+// Returns the desired rules missing in the current ones, the current rules whose description
+// differs from the desired one and the current rules not found in the desired ones.
+func diffFirewallRules(current []oneandone.FirewallPolicyRule, desired []firewallRuleRecord) (
+	missing []oneandone.FirewallPolicyRule, changed []firewallRuleChange, extra []oneandone.FirewallPolicyRule) {
+	byKey := make(map[string][]int)
+	for i := range desired {
+		key := desired[i].key()
+		byKey[key] = append(byKey[key], i)
+	}
+	// Rules are matched with their description first, the remaining ones by key only.
+	matched := make([]bool, len(desired))
+	var pending []oneandone.FirewallPolicyRule
+	for _, rule := range current {
+		record := newFirewallRuleRecord(&rule)
+		if i := takeFirewallRule(byKey, desired, matched, &record, true); i < 0 {
+			pending = append(pending, rule)
+		}
+	}
+	for _, rule := range pending {
+		record := newFirewallRuleRecord(&rule)
+		if i := takeFirewallRule(byKey, desired, matched, &record, false); i >= 0 {
+			changed = append(changed, firewallRuleChange{From: rule, To: desired[i].request()})
+		} else {
+			extra = append(extra, rule)
+		}
+	}
+	for i := range desired {
+		if !matched[i] {
+			missing = append(missing, desired[i].request())
+		}
+	}
+	return missing, changed, extra
+}
+
+// Rule whose description changed, it is replaced by a new rule.
+type firewallRuleChange struct {
+	From oneandone.FirewallPolicyRule `json:"from"`
+	To   oneandone.FirewallPolicyRule `json:"to"`
+}
+
+// Marks and returns the index of the first unmatched desired rule with the key of the
+// record and, if withDescription is set, its description, or returns -1 if there is none.
+func takeFirewallRule(byKey map[string][]int, desired []firewallRuleRecord, matched []bool,
+	record *firewallRuleRecord, withDescription bool) int {
+	key := record.key()
+	for _, i := range byKey[key] {
+		if matched[i] || withDescription && desired[i].Description != record.Description {
+			continue
+		}
+		matched[i] = true
+		return i
+	}
+	return -1
+}
+
+func firewallRuleChangeRow(change string, rule *oneandone.FirewallPolicyRule) []string {
+	record := newFirewallRuleRecord(rule)
+	return []string{change, record.Protocol, record.ports(), record.Source, record.Action, record.Description}
+}
+
+func readFirewallPolicyFile(fileName, format string) (*firewallPolicyFile, error) {
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	policy := new(firewallPolicyFile)
+	switch format {
+	case "csv":
+		policy.Rules, err = readFirewallCsv(bytes.NewReader(content))
+	case "yaml":
+		err = yaml.Unmarshal(content, policy)
+	case "iptables":
+		policy.Rules, err = readFirewallIptables(bytes.NewReader(content))
+	case "ufw":
+		policy.Rules, err = readFirewallUfw(bytes.NewReader(content))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %s", fileName, err.Error())
+	}
+	return policy, nil
+}
+
+func writeFirewallCsv(w io.Writer, policy *firewallPolicyFile) error {
+	writer := csv.NewWriter(w)
+	writer.Write(csvRuleHeader)
+	for _, r := range policy.Rules {
+		var from, to string
+		if r.hasPorts() {
+			from = strconv.Itoa(r.PortFrom)
+			to = strconv.Itoa(r.PortTo)
+		}
+		writer.Write([]string{r.Protocol, from, to, r.Source, r.Action, r.Description})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func readFirewallCsv(r io.Reader) ([]firewallRuleRecord, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	lines, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	var rules []firewallRuleRecord
+	for i, line := range lines {
+		if i == 0 && strings.EqualFold(strings.TrimSpace(line[0]), csvRuleHeader[0]) {
+			continue
+		}
+		for len(line) < len(csvRuleHeader) {
+			line = append(line, "")
+		}
+		rule := firewallRuleRecord{
+			Protocol:    strings.TrimSpace(line[0]),
+			Source:      strings.TrimSpace(line[3]),
+			Action:      strings.TrimSpace(line[4]),
+			Description: line[5],
+		}
+		if strings.TrimSpace(line[1]) != "" {
+			if rule.PortFrom, err = strconv.Atoi(strings.TrimSpace(line[1])); err != nil {
+				return nil, fmt.Errorf("line %d: invalid port_from '%s'", i+1, line[1])
+			}
+		}
+		if strings.TrimSpace(line[2]) != "" {
+			if rule.PortTo, err = strconv.Atoi(strings.TrimSpace(line[2])); err != nil {
+				return nil, fmt.Errorf("line %d: invalid port_to '%s'", i+1, line[2])
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Protocol names used by iptables and ufw.
+func firewallProtocols(protocol string) []string {
+	switch protocol {
+	case "TCP":
+		return []string{"tcp"}
+	case "UDP":
+		return []string{"udp"}
+	case "TCP/UDP":
+		return []string{"tcp", "udp"}
+	case "ICMP":
+		return []string{"icmp"}
+	case "IPSEC":
+		return []string{"esp"}
+	case "GRE":
+		return []string{"gre"}
+	}
+	return []string{""}
+}
+
+func firewallProtocol(name string) string {
+	switch strings.ToLower(name) {
+	case "tcp":
+		return "TCP"
+	case "udp":
+		return "UDP"
+	case "icmp":
+		return "ICMP"
+	case "esp", "ah", "ipsec":
+		return "IPSEC"
+	case "gre":
+		return "GRE"
+	case "", "all", "any":
+		return "ANY"
+	}
+	return strings.ToUpper(name)
+}
+
+// Writes the policy as iptables-restore file. Traffic not allowed by a rule is dropped like by the policy.
+func writeFirewallIptables(w io.Writer, policy *firewallPolicyFile) {
+	fmt.Fprintf(w, "# Firewall policy: %s\n", policy.Name)
+	fmt.Fprintln(w, "*filter")
+	fmt.Fprintln(w, ":INPUT DROP [0:0]")
+	fmt.Fprintln(w, ":FORWARD ACCEPT [0:0]")
+	fmt.Fprintln(w, ":OUTPUT ACCEPT [0:0]")
+	// The policy is stateful, replies to outgoing connections are allowed.
+	fmt.Fprintln(w, "-A INPUT -m conntrack --ctstate ESTABLISHED,RELATED -j ACCEPT")
+	for _, r := range policy.Rules {
+		source := r.Source
+		if source == anySource {
+			source = "0.0.0.0/0"
+		}
+		target := "ACCEPT"
+		if r.Action == "deny" {
+			target = "DROP"
+		}
+		for _, proto := range firewallProtocols(r.Protocol) {
+			line := "-A INPUT"
+			if proto != "" {
+				line += " -p " + proto
+			}
+			line += " -s " + source
+			if r.hasPorts() {
+				line += fmt.Sprintf(" --dport %d", r.PortFrom)
+				if r.PortTo != r.PortFrom {
+					line += fmt.Sprintf(":%d", r.PortTo)
+				}
+			}
+			if r.Description != "" {
+				line += " -m comment --comment " + strconv.Quote(r.Description)
+			}
+			fmt.Fprintf(w, "%s -j %s\n", line, target)
+		}
+	}
+	fmt.Fprintln(w, "COMMIT")
+}
+
+// Reads the rules of the INPUT chain from iptables-save output or iptables commands.
+// Rules of other chains are skipped, rules that cannot be expressed as policy rule are an error.
+func readFirewallIptables(r io.Reader) ([]firewallRuleRecord, error) {
+	var rules []firewallRuleRecord
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		tokens, err := splitRuleLine(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", n, err.Error())
+		}
+		if len(tokens) > 0 && (tokens[0] == "iptables" || tokens[0] == "ip6tables") {
+			tokens = tokens[1:]
+		}
+		if len(tokens) < 2 || (tokens[0] != "-A" && tokens[0] != "--append") || tokens[1] != "INPUT" {
+			continue
+		}
+		rule := firewallRuleRecord{}
+		var proto, state string
+		for i := 2; i < len(tokens); i++ {
+			option := tokens[i]
+			if option == "!" {
+				return nil, fmt.Errorf("line %d: negation with '!' cannot be imported", n)
+			}
+			if i == len(tokens)-1 {
+				return nil, fmt.Errorf("line %d: option %s has no value", n, option)
+			}
+			value := tokens[i+1]
+			if value == "!" {
+				return nil, fmt.Errorf("line %d: negation with '!' cannot be imported", n)
+			}
+			switch option {
+			case "-p", "--protocol":
+				proto = value
+			case "-s", "--source":
+				rule.Source = value
+			case "--dport", "--destination-port":
+				if rule.PortFrom, rule.PortTo, err = parsePortRange(value, ":"); err != nil {
+					return nil, fmt.Errorf("line %d: %s", n, err.Error())
+				}
+			case "-m", "--match":
+				switch value {
+				case "tcp", "udp", "comment", "conntrack", "state":
+				default:
+					return nil, fmt.Errorf("line %d: match '%s' cannot be imported", n, value)
+				}
+			case "--ctstate", "--state":
+				state = value
+			case "--comment":
+				rule.Description = value
+			case "-j", "--jump":
+				switch value {
+				case "ACCEPT":
+					rule.Action = "allow"
+				case "DROP":
+					rule.Action = "deny"
+				default:
+					return nil, fmt.Errorf("line %d: target %s cannot be imported, only ACCEPT and DROP are supported", n, value)
+				}
+			default:
+				return nil, fmt.Errorf("line %d: option %s cannot be imported", n, option)
+			}
+			i++
+		}
+		if rule.Action == "" {
+			return nil, fmt.Errorf("line %d: rule has no ACCEPT or DROP target", n)
+		}
+		if state != "" {
+			// Replies are allowed by the stateful policy without a rule.
+			if state == "ESTABLISHED,RELATED" || state == "RELATED,ESTABLISHED" {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: connection state %s cannot be imported", n, state)
+		}
+		rule.Protocol = firewallProtocol(proto)
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return mergeTcpUdpRules(rules), nil
+}
+
+func writeFirewallUfw(w io.Writer, policy *firewallPolicyFile) {
+	fmt.Fprintf(w, "# Firewall policy: %s\n", policy.Name)
+	for _, r := range policy.Rules {
+		if r.Protocol == "ICMP" {
+			fmt.Fprintln(w, "# ICMP rules cannot be expressed with ufw commands, skipped.")
+			continue
+		}
+		source := r.Source
+		if source == anySource {
+			source = "any"
+		}
+		protocols := firewallProtocols(r.Protocol)
+		// ufw allows both protocols for a single port when proto is omitted.
+		if r.Protocol == "TCP/UDP" && r.PortFrom == r.PortTo {
+			protocols = []string{""}
+		}
+		for _, proto := range protocols {
+			line := "ufw " + r.Action
+			if proto != "" {
+				line += " proto " + proto
+			}
+			line += " from " + source
+			if r.hasPorts() {
+				line += fmt.Sprintf(" to any port %d", r.PortFrom)
+				if r.PortTo != r.PortFrom {
+					line += fmt.Sprintf(":%d", r.PortTo)
+				}
+			}
+			if r.Description != "" {
+				line += " comment " + strconv.Quote(r.Description)
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+}
+
+// Ports and protocols of the service names accepted by ufw allow and deny.
+var ufwServices = map[string]string{
+	"ftp":    "21/tcp",
+	"ssh":    "22/tcp",
+	"telnet": "23/tcp",
+	"smtp":   "25/tcp",
+	"domain": "53",
+	"dns":    "53",
+	"http":   "80/tcp",
+	"pop3":   "110/tcp",
+	"imap":   "143/tcp",
+	"https":  "443/tcp",
+	"smtps":  "465/tcp",
+	"imaps":  "993/tcp",
+	"pop3s":  "995/tcp",
+	"mysql":  "3306/tcp",
+	"rdp":    "3389/tcp",
+}
+
+// Parses the short form of a ufw rule: port, port/protocol, port range/protocol or service name.
+func parseUfwPortSpec(spec string, rule *firewallRuleRecord) (proto string, err error) {
+	if service, ok := ufwServices[strings.ToLower(spec)]; ok {
+		spec = service
+	}
+	parts := strings.SplitN(spec, "/", 2)
+	if len(parts) == 2 {
+		proto = parts[1]
+	}
+	if rule.PortFrom, rule.PortTo, err = parsePortRange(parts[0], ":"); err != nil {
+		return "", fmt.Errorf("invalid port or unknown service '%s'", spec)
+	}
+	return proto, nil
+}
+
+func readFirewallUfw(r io.Reader) ([]firewallRuleRecord, error) {
+	var rules []firewallRuleRecord
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		tokens, err := splitRuleLine(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", n, err.Error())
+		}
+		if len(tokens) > 0 && tokens[0] == "ufw" {
+			tokens = tokens[1:]
+		}
+		if len(tokens) == 0 {
+			continue
+		}
+		rule := firewallRuleRecord{}
+		switch tokens[0] {
+		case "allow":
+			rule.Action = "allow"
+		case "deny", "reject":
+			rule.Action = "deny"
+		default:
+			continue
+		}
+		tokens = tokens[1:]
+		if len(tokens) > 0 && tokens[0] == "in" {
+			tokens = tokens[1:]
+		}
+		proto := ""
+		// Short form, e.g. ufw allow 22/tcp or ufw allow ssh.
+		if len(tokens) > 0 {
+			switch tokens[0] {
+			case "proto", "from", "to", "comment":
+			default:
+				if proto, err = parseUfwPortSpec(tokens[0], &rule); err != nil {
+					return nil, fmt.Errorf("line %d: %s", n, err.Error())
+				}
+				tokens = tokens[1:]
+			}
+		}
+		destination := false
+		for i := 0; i < len(tokens); i++ {
+			if i == len(tokens)-1 {
+				return nil, fmt.Errorf("line %d: %s has no value", n, tokens[i])
+			}
+			value := tokens[i+1]
+			switch tokens[i] {
+			case "proto":
+				proto = value
+			case "from":
+				rule.Source = value
+			case "to":
+				if value != "any" {
+					return nil, fmt.Errorf("line %d: destination '%s' cannot be imported", n, value)
+				}
+				destination = true
+			case "port":
+				if !destination {
+					return nil, fmt.Errorf("line %d: source ports cannot be imported", n)
+				}
+				if rule.PortFrom, rule.PortTo, err = parsePortRange(value, ":"); err != nil {
+					return nil, fmt.Errorf("line %d: %s", n, err.Error())
+				}
+			case "comment":
+				rule.Description = value
+			default:
+				return nil, fmt.Errorf("line %d: '%s' cannot be imported", n, tokens[i])
+			}
+			i++
+		}
+		if proto == "" && rule.PortFrom > 0 {
+			rule.Protocol = "TCP/UDP"
+		} else {
+			rule.Protocol = firewallProtocol(proto)
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return mergeTcpUdpRules(rules), nil
+}
+
+// Joins TCP and UDP rules that follow each other and only differ in protocol into one TCP/UDP rule.
+func mergeTcpUdpRules(rules []firewallRuleRecord) []firewallRuleRecord {
+	var merged []firewallRuleRecord
+	for i := 0; i < len(rules); i++ {
+		if i+1 < len(rules) && rules[i].Protocol == "TCP" && rules[i+1].Protocol == "UDP" {
+			udp := rules[i+1]
+			udp.Protocol = "TCP"
+			udp.normalize()
+			tcp := rules[i]
+			tcp.normalize()
+			if udp.key() == tcp.key() && udp.Description == tcp.Description {
+				tcp.Protocol = "TCP/UDP"
+				merged = append(merged, tcp)
+				i++
+				continue
+			}
+		}
+		merged = append(merged, rules[i])
+	}
+	return merged
+}
+
+// Splits a rule line into tokens honoring single and double quotes. Comments are skipped.
+func splitRuleLine(line string) ([]string, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil, nil
+	}
+	var tokens []string
+	var token []rune
+	var quote rune
+	inToken := false
+	escaped := false
+	for _, c := range line {
+		switch {
+		case escaped:
+			token = append(token, c)
+			escaped = false
+		case c == '\\' && quote == '"':
+			escaped = true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				token = append(token, c)
+			}
+		case c == '"' || c == '\'':
+			quote = c
+			inToken = true
+		case c == ' ' || c == '\t':
+			if inToken {
+				tokens = append(tokens, string(token))
+				token = token[:0]
+				inToken = false
+			}
+		default:
+			token = append(token, c)
+			inToken = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if inToken {
+		tokens = append(tokens, string(token))
+	}
+	return tokens, nil
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/1and1/oneandone-cloudserver-sdk-go"
+)
+
+var testFirewallPolicy = &firewallPolicyFile{
+	Name: "web",
+	Rules: []firewallRuleRecord{
+		{Protocol: "TCP", PortFrom: 22, PortTo: 22, Source: "10.0.0.0/8", Action: "allow", Description: "ssh"},
+		{Protocol: "TCP/UDP", PortFrom: 8000, PortTo: 8080, Source: anySource, Action: "allow"},
+		{Protocol: "ICMP", Source: anySource, Action: "allow"},
+		{Protocol: "UDP", PortFrom: 53, PortTo: 53, Source: "192.168.1.1", Action: "deny"},
+	},
+}
+
+func assertFirewallRules(t *testing.T, name string, expected, got []firewallRuleRecord) {
+	for i := range got {
+		got[i].normalize()
+	}
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("%s: expected rules %+v, got %+v", name, expected, got)
+	}
+}
+
+func TestFirewallRulesRoundTrip(t *testing.T) {
+	formats := []struct {
+		name  string
+		write func(*bytes.Buffer)
+		read  func(*bytes.Buffer) ([]firewallRuleRecord, error)
+		rules []firewallRuleRecord
+	}{
+		{
+			"csv",
+			func(b *bytes.Buffer) { writeFirewallCsv(b, testFirewallPolicy) },
+			func(b *bytes.Buffer) ([]firewallRuleRecord, error) { return readFirewallCsv(b) },
+			testFirewallPolicy.Rules,
+		},
+		{
+			"iptables",
+			func(b *bytes.Buffer) { writeFirewallIptables(b, testFirewallPolicy) },
+			func(b *bytes.Buffer) ([]firewallRuleRecord, error) { return readFirewallIptables(b) },
+			testFirewallPolicy.Rules,
+		},
+		{
+			// ufw cannot express ICMP rules.
+			"ufw",
+			func(b *bytes.Buffer) { writeFirewallUfw(b, testFirewallPolicy) },
+			func(b *bytes.Buffer) ([]firewallRuleRecord, error) { return readFirewallUfw(b) },
+			[]firewallRuleRecord{testFirewallPolicy.Rules[0], testFirewallPolicy.Rules[1], testFirewallPolicy.Rules[3]},
+		},
+	}
+	for _, f := range formats {
+		var b bytes.Buffer
+		f.write(&b)
+		rules, err := f.read(&b)
+		if err != nil {
+			t.Errorf("%s: %s", f.name, err.Error())
+			continue
+		}
+		assertFirewallRules(t, f.name, f.rules, rules)
+	}
+}
+
+func TestDiffFirewallRules(t *testing.T) {
+	var current []oneandone.FirewallPolicyRule
+	for i, r := range testFirewallPolicy.Rules {
+		rule := r.request()
+		rule.Id = fmt.Sprintf("R%d", i+1)
+		current = append(current, rule)
+	}
+	desired := []firewallRuleRecord{
+		testFirewallPolicy.Rules[0],
+		{Protocol: "TCP/UDP", PortFrom: 8000, PortTo: 8080, Source: anySource, Action: "allow", Description: "web"},
+		{Protocol: "TCP", PortFrom: 443, PortTo: 443, Source: anySource, Action: "allow"},
+		testFirewallPolicy.Rules[3],
+	}
+	missing, changed, extra := diffFirewallRules(current, desired)
+	if len(missing) != 1 || *missing[0].PortFrom != 443 {
+		t.Errorf("expected the 443 rule to be added, got %+v", missing)
+	}
+	if len(changed) != 1 || changed[0].From.Id != "R2" || changed[0].From.Description != "" || changed[0].To.Description != "web" {
+		t.Errorf("expected the description of rule R2 to change, got %+v", changed)
+	}
+	if len(extra) != 1 || extra[0].Id != "R3" {
+		t.Errorf("expected rule R3 to be removed, got %+v", extra)
+	}
+}
+
+func TestWriteFirewallIptables(t *testing.T) {
+	var b bytes.Buffer
+	writeFirewallIptables(&b, testFirewallPolicy)
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	expected := []string{
+		"# Firewall policy: web",
+		"*filter",
+		":INPUT DROP [0:0]",
+		":FORWARD ACCEPT [0:0]",
+		":OUTPUT ACCEPT [0:0]",
+		"-A INPUT -m conntrack --ctstate ESTABLISHED,RELATED -j ACCEPT",
+		`-A INPUT -p tcp -s 10.0.0.0/8 --dport 22 -m comment --comment "ssh" -j ACCEPT`,
+		"-A INPUT -p tcp -s 0.0.0.0/0 --dport 8000:8080 -j ACCEPT",
+		"-A INPUT -p udp -s 0.0.0.0/0 --dport 8000:8080 -j ACCEPT",
+		"-A INPUT -p icmp -s 0.0.0.0/0 -j ACCEPT",
+		"-A INPUT -p udp -s 192.168.1.1 --dport 53 -j DROP",
+		"COMMIT",
+	}
+	if !reflect.DeepEqual(expected, lines) {
+		t.Errorf("expected iptables file:\n%s\ngot:\n%s", strings.Join(expected, "\n"), b.String())
+	}
+}
+
+func TestReadFirewallCsv(t *testing.T) {
+	tests := []struct {
+		in    string
+		rules []firewallRuleRecord
+		err   string
+	}{
+		{
+			"protocol,port_from,port_to,source,action,description\nTCP,80,,,,web\n",
+			[]firewallRuleRecord{{Protocol: "TCP", PortFrom: 80, PortTo: 80, Source: anySource, Action: "allow", Description: "web"}},
+			"",
+		},
+		{"# comment\nUDP,1000,2000,1.2.3.4,deny\n",
+			[]firewallRuleRecord{{Protocol: "UDP", PortFrom: 1000, PortTo: 2000, Source: "1.2.3.4", Action: "deny"}},
+			"",
+		},
+		{"TCP,http\n", nil, "line 1: invalid port_from 'http'"},
+	}
+	for _, test := range tests {
+		rules, err := readFirewallCsv(strings.NewReader(test.in))
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%q: expected error '%s', got %v", test.in, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %s", test.in, err.Error())
+			continue
+		}
+		assertFirewallRules(t, test.in, test.rules, rules)
+	}
+}
+
+func TestReadFirewallIptables(t *testing.T) {
+	tests := []struct {
+		in    string
+		rules []firewallRuleRecord
+		err   string
+	}{
+		{
+			"*filter\n:INPUT DROP [0:0]\n-A INPUT -p tcp --dport 443 -j ACCEPT\n-A OUTPUT -p tcp --dport 25 -j DROP\nCOMMIT\n",
+			[]firewallRuleRecord{{Protocol: "TCP", PortFrom: 443, PortTo: 443, Source: anySource, Action: "allow"}},
+			"",
+		},
+		{
+			"iptables -A INPUT -p udp -s 10.1.1.1 --dport 53 -m comment --comment 'dns server' -j DROP\n",
+			[]firewallRuleRecord{{Protocol: "UDP", PortFrom: 53, PortTo: 53, Source: "10.1.1.1", Action: "deny", Description: "dns server"}},
+			"",
+		},
+		{
+			"-A INPUT -m state --state RELATED,ESTABLISHED -j ACCEPT\n-A INPUT -p icmp -j ACCEPT\n",
+			[]firewallRuleRecord{{Protocol: "ICMP", Source: anySource, Action: "allow"}},
+			"",
+		},
+		{"*filter\n-A INPUT -p tcp ! -s 10.0.0.0/8 -j ACCEPT\n", nil, "line 2: negation with '!' cannot be imported"},
+		{"-A INPUT -p tcp -s ! 10.0.0.0/8 -j ACCEPT\n", nil, "line 1: negation with '!' cannot be imported"},
+		{"-A INPUT -p tcp --dport 22 -j REJECT\n", nil, "line 1: target REJECT cannot be imported, only ACCEPT and DROP are supported"},
+		{"-A INPUT -p tcp --dport 22 -j LOG\n", nil, "line 1: target LOG cannot be imported, only ACCEPT and DROP are supported"},
+		{"-A INPUT -i lo -j ACCEPT\n", nil, "line 1: option -i cannot be imported"},
+		{"-A INPUT -p tcp --dport 22\n", nil, "line 1: rule has no ACCEPT or DROP target"},
+		{"-A INPUT -m state --state NEW -j ACCEPT\n", nil, "line 1: connection state NEW cannot be imported"},
+	}
+	for _, test := range tests {
+		rules, err := readFirewallIptables(strings.NewReader(test.in))
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%q: expected error '%s', got %v", test.in, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %s", test.in, err.Error())
+			continue
+		}
+		assertFirewallRules(t, test.in, test.rules, rules)
+	}
+}
+
+func TestReadFirewallUfw(t *testing.T) {
+	tests := []struct {
+		in    string
+		rules []firewallRuleRecord
+		err   string
+	}{
+		{"ufw allow 22\n", []firewallRuleRecord{{Protocol: "TCP/UDP", PortFrom: 22, PortTo: 22, Source: anySource, Action: "allow"}}, ""},
+		{"ufw allow 22/tcp\n", []firewallRuleRecord{{Protocol: "TCP", PortFrom: 22, PortTo: 22, Source: anySource, Action: "allow"}}, ""},
+		{"ufw allow 6000:6007/udp\n", []firewallRuleRecord{{Protocol: "UDP", PortFrom: 6000, PortTo: 6007, Source: anySource, Action: "allow"}}, ""},
+		{"ufw allow ssh\nufw deny https\n", []firewallRuleRecord{
+			{Protocol: "TCP", PortFrom: 22, PortTo: 22, Source: anySource, Action: "allow"},
+			{Protocol: "TCP", PortFrom: 443, PortTo: 443, Source: anySource, Action: "deny"},
+		}, ""},
+		{"ufw allow in dns\n", []firewallRuleRecord{{Protocol: "TCP/UDP", PortFrom: 53, PortTo: 53, Source: anySource, Action: "allow"}}, ""},
+		{
+			"ufw allow proto tcp from 10.0.0.0/8 to any port 80 comment 'web'\n",
+			[]firewallRuleRecord{{Protocol: "TCP", PortFrom: 80, PortTo: 80, Source: "10.0.0.0/8", Action: "allow", Description: "web"}},
+			"",
+		},
+		{"ufw allow from 1.2.3.4\n", []firewallRuleRecord{{Protocol: "ANY", Source: "1.2.3.4", Action: "allow"}}, ""},
+		{"ufw enable\nufw allow gopher\n", nil, "line 2: invalid port or unknown service 'gopher'"},
+		{"ufw allow from 1.2.3.4 port 53\n", nil, "line 1: source ports cannot be imported"},
+		{"ufw allow to 10.0.0.1 port 22\n", nil, "line 1: destination '10.0.0.1' cannot be imported"},
+	}
+	for _, test := range tests {
+		rules, err := readFirewallUfw(strings.NewReader(test.in))
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%q: expected error '%s', got %v", test.in, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %s", test.in, err.Error())
+			continue
+		}
+		assertFirewallRules(t, test.in, test.rules, rules)
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/1and1/oneandone-cloudserver-sdk-go"
+	"github.com/codegangsta/cli"
+	"gopkg.in/yaml.v2"
+)
+
+var inventoryOps []cli.Command
+
+const inventoryFormats = "ansible-ini, ansible-yaml, ssh-config, hosts or json"
+
+var (
+	inventoryNameChars  = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+	inventoryGroupChars = regexp.MustCompile(`[^a-z0-9_]+`)
+	descriptionTag      = regexp.MustCompile(`#([A-Za-z0-9_-]+)`)
+)
+
+func init() {
+	inventoryOps = []cli.Command{
+		{
+			Name:        "inventory",
+			HelpName:    appName + " inventory",
+			Description: "1&1 server inventory for Ansible, SSH config and hosts files",
+			Usage:       "Generates inventory of servers.",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "format",
+					Value: "ansible-ini",
+					Usage: "Inventory format: " + inventoryFormats + ".",
+				},
+				cli.BoolFlag{
+					Name:  "list",
+					Usage: "Print whole inventory as JSON for Ansible dynamic inventory.",
+				},
+				cli.StringFlag{
+					Name:  "host",
+					Usage: "Print variables of the host as JSON for Ansible dynamic inventory.",
+				},
+			},
+			Action: showInventory,
+		},
+	}
+}
+
+// Server as it appears in the inventory.
+type inventoryHost struct {
+	name   string
+	groups []string
+	vars   map[string]interface{}
+	server *oneandone.Server
+}
+
+func inventoryGroup(prefix, name string) string {
+	return prefix + "_" + strings.Trim(inventoryGroupChars.ReplaceAllString(strings.ToLower(name), "_"), "_")
+}
+
+// Returns the primary public IP of the server, preferring IPv4 addresses.
+func getServerPrimaryIp(server *oneandone.Server) string {
+	for _, ip := range server.Ips {
+		if ip.Type != "IPV6" {
+			return ip.Ip
+		}
+	}
+	return getServerPublicIp(server)
+}
+
+func newInventory(servers []oneandone.Server) ([]*inventoryHost, map[string][]string) {
+	hosts := make([]*inventoryHost, 0, len(servers))
+	groups := make(map[string][]string)
+	names := make(map[string]bool)
+	for i := range servers {
+		server := &servers[i]
+		name := strings.Trim(inventoryNameChars.ReplaceAllString(server.Name, "-"), "-")
+		if name == "" || names[name] {
+			name = strings.TrimPrefix(name+"-", "-") + server.Id[:8]
+		}
+		names[name] = true
+
+		host := &inventoryHost{name: name, server: server, vars: map[string]interface{}{
+			"oneandone_id":   server.Id,
+			"oneandone_name": server.Name,
+		}}
+		if ip := getServerPrimaryIp(server); ip != "" {
+			host.vars["ansible_host"] = ip
+		}
+		var publicIps []string
+		addGroup := func(group string) {
+			for _, g := range host.groups {
+				if g == group {
+					return
+				}
+			}
+			host.groups = append(host.groups, group)
+		}
+		for _, ip := range server.Ips {
+			publicIps = append(publicIps, ip.Ip)
+			if ip.Firewall != nil {
+				addGroup(inventoryGroup("fw", firstNonEmpty(ip.Firewall.Name, ip.Firewall.Id)))
+			}
+			for _, lb := range ip.LoadBalancers {
+				addGroup(inventoryGroup("lb", firstNonEmpty(lb.Name, lb.Id)))
+			}
+		}
+		if len(publicIps) > 0 {
+			host.vars["oneandone_public_ips"] = publicIps
+		}
+		if len(server.PrivateNets) > 0 {
+			privateIps := make(map[string]string)
+			for _, pn := range server.PrivateNets {
+				privateIps[firstNonEmpty(pn.Name, pn.Id)] = pn.ServerIP
+			}
+			host.vars["oneandone_private_ips"] = privateIps
+		}
+		if server.Datacenter != nil {
+			host.vars["oneandone_datacenter"] = server.Datacenter.CountryCode
+			addGroup(inventoryGroup("dc", server.Datacenter.CountryCode))
+		}
+		if server.Image != nil {
+			host.vars["oneandone_os"] = server.Image.Name
+		}
+		if server.Status != nil {
+			host.vars["oneandone_state"] = server.Status.State
+		}
+		for _, tag := range descriptionTag.FindAllStringSubmatch(server.Description, -1) {
+			addGroup(inventoryGroup("tag", tag[1]))
+		}
+
+		sort.Strings(host.groups)
+		for _, g := range host.groups {
+			groups[g] = append(groups[g], host.name)
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts, groups
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func sortedGroupNames(groups map[string][]string) []string {
+	names := make([]string, 0, len(groups))
+	for g := range groups {
+		names = append(names, g)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Builds the JSON document expected from an Ansible dynamic inventory script called with --list.
+func ansibleInventoryJson(hosts []*inventoryHost, groups map[string][]string) map[string]interface{} {
+	hostVars := make(map[string]interface{})
+	all := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		hostVars[h.name] = h.vars
+		all = append(all, h.name)
+	}
+	inventory := map[string]interface{}{
+		"_meta": map[string]interface{}{"hostvars": hostVars},
+		"all":   map[string]interface{}{"hosts": all, "children": sortedGroupNames(groups)},
+	}
+	for g, members := range groups {
+		inventory[g] = map[string]interface{}{"hosts": members}
+	}
+	return inventory
+}
+
+func printJson(in interface{}) {
+	bytes, err := json.MarshalIndent(in, "", "    ")
+	exitOnError(err)
+	fmt.Println(string(bytes))
+}
+
+func showInventory(ctx *cli.Context) {
+	format := strings.ToLower(ctx.String("format"))
+	if ctx.Bool("list") {
+		format = "json"
+	}
+	switch format {
+	case "json", "ansible-ini", "ansible-yaml", "ssh-config", "hosts":
+	default:
+		exitOnError(fmt.Errorf("--format must be either %s", inventoryFormats))
+	}
+
+	ensureClient(ctx)
+	servers, err := api.ListServers()
+	exitOnError(err)
+	hosts, groups := newInventory(servers)
+
+	if ctx.String("host") != "" {
+		for _, h := range hosts {
+			if h.name == ctx.String("host") {
+				printJson(h.vars)
+				return
+			}
+		}
+		printJson(map[string]interface{}{})
+		return
+	}
+	switch format {
+	case "json":
+		printJson(ansibleInventoryJson(hosts, groups))
+	case "ansible-ini":
+		fmt.Println("[oneandone]")
+		for _, h := range hosts {
+			line := h.name
+			for _, key := range []string{"ansible_host", "oneandone_id", "oneandone_datacenter", "oneandone_state"} {
+				if v, ok := h.vars[key]; ok && v != "" {
+					line += fmt.Sprintf(" %s=%v", key, v)
+				}
+			}
+			fmt.Println(line)
+		}
+		for _, g := range sortedGroupNames(groups) {
+			fmt.Printf("\n[%s]\n%s\n", g, strings.Join(groups[g], "\n"))
+		}
+	case "ansible-yaml":
+		hostVars := yaml.MapSlice{}
+		for _, h := range hosts {
+			hostVars = append(hostVars, yaml.MapItem{Key: h.name, Value: h.vars})
+		}
+		children := yaml.MapSlice{}
+		for _, g := range sortedGroupNames(groups) {
+			members := yaml.MapSlice{}
+			for _, m := range groups[g] {
+				members = append(members, yaml.MapItem{Key: m, Value: map[string]interface{}{}})
+			}
+			children = append(children, yaml.MapItem{Key: g, Value: yaml.MapSlice{{Key: "hosts", Value: members}}})
+		}
+		all := yaml.MapSlice{{Key: "hosts", Value: hostVars}}
+		if len(children) > 0 {
+			all = append(all, yaml.MapItem{Key: "children", Value: children})
+		}
+		content, err := yaml.Marshal(yaml.MapSlice{{Key: "all", Value: all}})
+		exitOnError(err)
+		fmt.Print(string(content))
+	case "ssh-config":
+		for _, h := range hosts {
+			ip, ok := h.vars["ansible_host"]
+			if !ok {
+				continue
+			}
+			fmt.Printf("# %s (%s)\nHost %s\n    HostName %s\n\n", h.server.Name, h.server.Id, h.name, ip)
+		}
+	case "hosts":
+		for _, h := range hosts {
+			if ip, ok := h.vars["ansible_host"]; ok {
+				fmt.Printf("%s\t%s\n", ip, h.name)
+			}
+		}
+	}
+}
@@ -57,7 +57,7 @@ func init() {
 	}
 	hctFlag := cli.StringFlag{
 		Name:  "hctest",
-		Usage: "Health check test: NONE, TCP or ICMP.",
+		Usage: "Health check test: NONE, TCP, ICMP or HTTP.",
 	}
 	hciFlag := cli.StringFlag{
 		Name:  "hctime",
@@ -104,6 +104,25 @@ func init() {
 			Description: "1&1 load balancer operations",
 			Usage:       "Load balancer operations.",
 			Subcommands: []cli.Command{
+				{
+					Name:  "apply",
+					Usage: "Creates load balancer from YAML file or updates existing one to match it.",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "file, f",
+							Usage: "Path to the load balancer YAML file.",
+						},
+						cli.StringFlag{
+							Name:  "id, i",
+							Usage: "ID of the load balancer to update. By default the load balancer is matched by name.",
+						},
+						cli.BoolFlag{
+							Name:  "dryrun",
+							Usage: "Only show the changes needed to apply the file.",
+						},
+					},
+					Action: applyLoadBalancer,
+				},
 				{
 					Name:  "assign",
 					Usage: "Assigns servers/IPs to load balancer.",
@@ -116,12 +135,41 @@ func init() {
 					},
 					Action: assignLoadBalancerServers,
 				},
+				{
+					Name:  "check",
+					Usage: "Probes servers behind load balancer and reports their health.",
+					Flags: []cli.Flag{
+						lbIdFlag,
+						cli.BoolFlag{
+							Name:  "http",
+							Usage: "Probe server ports over HTTP even if the health check test is not HTTP.",
+						},
+						cli.IntFlag{
+							Name:  "timeout",
+							Value: 5,
+							Usage: "Timeout of each probe in seconds.",
+						},
+					},
+					Action: checkLoadBalancer,
+				},
 				{
 					Name:   "create",
 					Usage:  "Creates new load balancer.",
 					Flags:  lbCreateFlags,
 					Action: createLoadBalancer,
 				},
+				{
+					Name:  "export",
+					Usage: "Exports load balancer configuration to YAML.",
+					Flags: []cli.Flag{
+						lbIdFlag,
+						cli.StringFlag{
+							Name:  "file, f",
+							Usage: "Path to the output file. Prints to standard output if not set.",
+						},
+					},
+					Action: exportLoadBalancer,
+				},
 				{
 					Name:   "info",
 					Usage:  "Shows information about load balancer.",
@@ -243,7 +291,14 @@ func parseLoadBalancerRules(ctx *cli.Context) []oneandone.LoadBalancerRule {
 }
 
 func parseLBMethod(ctx *cli.Context) string {
-	method := strings.ToUpper(getRequiredOption(ctx, "method"))
+	method, err := validateLBMethod("--method flag", getRequiredOption(ctx, "method"))
+	exitOnError(err)
+	return method
+}
+
+// Returns the balancing method for a method or its alias, the error names the setting.
+func validateLBMethod(setting, method string) (string, error) {
+	method = strings.ToUpper(method)
 	switch method {
 	case "RR":
 		method = "ROUND_ROBIN"
@@ -256,19 +311,26 @@ func parseLBMethod(ctx *cli.Context) string {
 	case "LEAST_CONNECTIONS":
 		break
 	default:
-		exitOnError(fmt.Errorf("Invalid value for --method flag. Valid values are ROUND_ROBIN and LEAST_CONNECTIONS."))
+		return "", fmt.Errorf("Invalid value for %s. Valid values are ROUND_ROBIN and LEAST_CONNECTIONS.", setting)
 	}
-	return method
+	return method, nil
 }
 
 func parseHCTest(ctx *cli.Context) string {
-	hcTest := strings.ToUpper(getRequiredOption(ctx, "hctest"))
-	if hcTest != "NONE" && hcTest != "TCP" && hcTest != "ICMP" {
-		exitOnError(fmt.Errorf("Invalid value for --hctest flag. Valid values are NONE, TCP and ICMP."))
-	}
+	hcTest, err := validateHCTest("--hctest flag", getRequiredOption(ctx, "hctest"))
+	exitOnError(err)
 	return hcTest
 }
 
+// Returns the health check test in upper case, the error names the setting.
+func validateHCTest(setting, hcTest string) (string, error) {
+	hcTest = strings.ToUpper(hcTest)
+	if hcTest != "NONE" && hcTest != "TCP" && hcTest != "ICMP" && hcTest != "HTTP" {
+		return "", fmt.Errorf("Invalid value for %s. Valid values are NONE, TCP, ICMP and HTTP.", setting)
+	}
+	return hcTest, nil
+}
+
 ////////////////////////////////////////////////////////////////////////////
 
 func listLoadBalancers(ctx *cli.Context) {
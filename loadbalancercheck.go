@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/1and1/oneandone-cloudserver-sdk-go"
+	"github.com/codegangsta/cli"
+)
+
+// Bytes of an HTTP response read to match the health check regular expression.
+const healthCheckBodyLimit = 1 << 20
+
+// Exit statuses of 'loadbalancer check' other than 0 for a healthy load balancer.
+const (
+	healthDegradedStatus = 1
+	healthUnknownStatus  = 2
+)
+
+// Result of probing one backend port behind a load balancer.
+type backendCheck struct {
+	ServerName string `json:"server_name"`
+	Ip         string `json:"ip"`
+	Protocol   string `json:"protocol"`
+	Port       int    `json:"port"`
+	Reachable  bool   `json:"reachable"`
+	Checked    bool   `json:"checked"`
+	LatencyMs  int64  `json:"latency_ms,omitempty"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Match      *bool  `json:"regex_match,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+func (c *backendCheck) healthy() bool {
+	return !c.Checked || (c.Reachable && c.StatusCode < 400 && (c.Match == nil || *c.Match))
+}
+
+func checkLoadBalancer(ctx *cli.Context) {
+	lbId := getRequiredOption(ctx, "id")
+	timeout := time.Duration(validateIntRange("timeout", ctx.Int("timeout"), 1, 300)) * time.Second
+
+	lb, err := api.GetLoadBalancer(lbId)
+	exitOnErrorStatus(err, healthUnknownStatus)
+	servers, err := api.ListLoadBalancerServerIps(lbId)
+	exitOnErrorStatus(err, healthUnknownStatus)
+	if len(servers) == 0 {
+		exitOnErrorStatus(fmt.Errorf("Health: DEGRADED, no server IPs are assigned to load balancer %s", lb.Name),
+			healthDegradedStatus)
+	}
+
+	var parser *regexp.Regexp
+	// A path may be left over from an earlier HTTP check, only the test decides how the API checks.
+	useHttp := ctx.Bool("http") || strings.EqualFold(lb.HealthCheckTest, "HTTP")
+	if useHttp && lb.HealthCheckPathParser != "" {
+		parser, err = regexp.Compile(lb.HealthCheckPathParser)
+		exitOnErrorStatus(err, healthUnknownStatus)
+	}
+
+	var checks []*backendCheck
+	for _, server := range servers {
+		for _, rule := range lb.Rules {
+			checks = append(checks, &backendCheck{
+				ServerName: server.ServerName,
+				Ip:         server.Ip,
+				Protocol:   strings.ToUpper(rule.Protocol),
+				Port:       int(rule.PortServer),
+			})
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, c := range checks {
+		wg.Add(1)
+		go func(c *backendCheck) {
+			defer wg.Done()
+			probeBackend(c, lb, useHttp, parser, timeout)
+		}(c)
+	}
+	wg.Wait()
+
+	failed, unchecked := 0, 0
+	data := make([][]string, len(checks))
+	for i, c := range checks {
+		reachable, latency, match := "not checked", "", ""
+		if c.Checked {
+			reachable = strconv.FormatBool(c.Reachable)
+			if c.Reachable {
+				latency = strconv.FormatInt(c.LatencyMs, 10) + " ms"
+			}
+		}
+		if c.Match != nil {
+			match = strconv.FormatBool(*c.Match)
+		}
+		if !c.Checked {
+			unchecked++
+		} else if !c.healthy() {
+			failed++
+		}
+		data[i] = []string{c.ServerName, c.Ip, c.Protocol, strconv.Itoa(c.Port), reachable, latency, match, c.Error}
+	}
+	header := []string{"Server", "IP Address", "Protocol", "Port", "Reachable", "Latency", "Regex Match", "Error"}
+	checked := len(checks) - unchecked
+	var message string
+	status := 0
+	switch {
+	case failed > 0:
+		message = fmt.Sprintf("Health: DEGRADED, %d of %d checks failed", failed, checked)
+		status = healthDegradedStatus
+	case checked == 0:
+		message = fmt.Sprintf("Health: UNKNOWN, none of the %d backends could be probed", len(checks))
+		status = healthUnknownStatus
+	default:
+		message = fmt.Sprintf("Health: OK, all %d checks passed", checked)
+	}
+	if unchecked > 0 && checked > 0 {
+		message += fmt.Sprintf(", %d not checked", unchecked)
+	}
+	output(ctx, checks, message+".\n", false, &header, &data)
+
+	if status != 0 {
+		os.Exit(status)
+	}
+}
+
+// Probes a backend port over TCP or HTTP. UDP ports cannot be probed reliably and are skipped.
+func probeBackend(c *backendCheck, lb *oneandone.LoadBalancer, useHttp bool, parser *regexp.Regexp, timeout time.Duration) {
+	if c.Protocol != "TCP" {
+		c.Error = c.Protocol + " backends are not probed"
+		return
+	}
+	c.Checked = true
+	address := net.JoinHostPort(c.Ip, strconv.Itoa(c.Port))
+	start := time.Now()
+
+	if !useHttp {
+		conn, err := net.DialTimeout("tcp", address, timeout)
+		if err != nil {
+			c.Error = err.Error()
+			return
+		}
+		conn.Close()
+		c.Reachable = true
+		c.LatencyMs = time.Since(start).Nanoseconds() / int64(time.Millisecond)
+		return
+	}
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get("http://" + address + healthCheckPath(lb.HealthCheckPath))
+	if err != nil {
+		c.Error = err.Error()
+		return
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, healthCheckBodyLimit))
+	c.LatencyMs = time.Since(start).Nanoseconds() / int64(time.Millisecond)
+	if err != nil {
+		c.Error = err.Error()
+		return
+	}
+	c.Reachable = true
+	c.StatusCode = resp.StatusCode
+	if resp.StatusCode >= 400 {
+		c.Error = resp.Status
+	}
+	if parser != nil {
+		c.Match = oneandone.Bool2Pointer(parser.Match(body))
+	}
+}
+
+// Returns the path part of the health check path, which may also be given as a full URL.
+func healthCheckPath(path string) string {
+	if u, err := url.Parse(path); err == nil && u.IsAbs() {
+		path = u.RequestURI()
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return path
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/1and1/oneandone-cloudserver-sdk-go"
+	"github.com/codegangsta/cli"
+	"gopkg.in/yaml.v2"
+)
+
+// Load balancer configuration as it is written to and read from a YAML file.
+type loadBalancerFile struct {
+	Name            string                 `yaml:"name"`
+	Description     string                 `yaml:"description,omitempty"`
+	DatacenterId    string                 `yaml:"datacenter_id,omitempty"`
+	Method          string                 `yaml:"method"`
+	Persistence     bool                   `yaml:"persistence"`
+	PersistenceTime int                    `yaml:"persistence_time,omitempty"`
+	HealthCheck     loadBalancerHealth     `yaml:"health_check"`
+	Rules           []loadBalancerRuleSpec `yaml:"rules"`
+	// Server IPs are left unchanged when the key is missing.
+	ServerIps *[]loadBalancerServerIp `yaml:"server_ips,omitempty"`
+}
+
+type loadBalancerHealth struct {
+	Test     string `yaml:"test"`
+	Interval int    `yaml:"interval"`
+	Path     string `yaml:"path,omitempty"`
+	Parser   string `yaml:"parser,omitempty"`
+}
+
+type loadBalancerRuleSpec struct {
+	Protocol     string `yaml:"protocol"`
+	PortBalancer int    `yaml:"port_balancer"`
+	PortServer   int    `yaml:"port_server"`
+	Source       string `yaml:"source,omitempty"`
+}
+
+type loadBalancerServerIp struct {
+	Id         string `yaml:"id,omitempty"`
+	Ip         string `yaml:"ip,omitempty"`
+	ServerName string `yaml:"server_name,omitempty"`
+}
+
+// Difference between a resource and its configuration file as shown by the apply commands.
+type configChange struct {
+	Change  string `json:"change"`
+	Field   string `json:"field"`
+	Current string `json:"current,omitempty"`
+	Desired string `json:"desired,omitempty"`
+}
+
+func outputConfigChanges(ctx *cli.Context, changes []configChange) {
+	data := make([][]string, len(changes))
+	for i, c := range changes {
+		data[i] = []string{c.Change, c.Field, c.Current, c.Desired}
+	}
+	header := []string{"Change", "Field", "Current", "Desired"}
+	output(ctx, changes, "", false, &header, &data)
+}
+
+// Checks a value of a configuration file, naming its key in the error.
+func validateFileRange(key string, value, min, max int) {
+	if value < min || value > max {
+		exitOnError(fmt.Errorf("%s must be an integer in range [%d %d], got %d", key, min, max, value))
+	}
+}
+
+func (r *loadBalancerRuleSpec) key() string {
+	source := r.Source
+	if source == "" || source == "0.0.0.0/0" {
+		source = anySource
+	}
+	return fmt.Sprintf("%s|%d|%d|%s", strings.ToUpper(r.Protocol), r.PortBalancer, r.PortServer, source)
+}
+
+func (r *loadBalancerRuleSpec) String() string {
+	source := r.Source
+	if source == "" {
+		source = anySource
+	}
+	return fmt.Sprintf("%s %d -> %d from %s", strings.ToUpper(r.Protocol), r.PortBalancer, r.PortServer, source)
+}
+
+func newLoadBalancerFile(lb *oneandone.LoadBalancer, ips []oneandone.ServerIpInfo) *loadBalancerFile {
+	config := &loadBalancerFile{
+		Name:            lb.Name,
+		Description:     lb.Description,
+		Method:          lb.Method,
+		Persistence:     lb.Persistence,
+		PersistenceTime: lb.PersistenceTime,
+		HealthCheck: loadBalancerHealth{
+			Test:     lb.HealthCheckTest,
+			Interval: lb.HealthCheckInterval,
+			Path:     lb.HealthCheckPath,
+			Parser:   lb.HealthCheckPathParser,
+		},
+	}
+	if lb.Datacenter != nil {
+		config.DatacenterId = lb.Datacenter.Id
+	}
+	for _, rule := range lb.Rules {
+		config.Rules = append(config.Rules, loadBalancerRuleSpec{
+			Protocol:     rule.Protocol,
+			PortBalancer: int(rule.PortBalancer),
+			PortServer:   int(rule.PortServer),
+			Source:       rule.Source,
+		})
+	}
+	serverIps := []loadBalancerServerIp{}
+	for _, ip := range ips {
+		serverIps = append(serverIps, loadBalancerServerIp{Id: ip.Id, Ip: ip.Ip, ServerName: ip.ServerName})
+	}
+	config.ServerIps = &serverIps
+	return config
+}
+
+// Validates the configuration the same way as the flags of 'loadbalancer create'.
+// Errors name the keys of the file.
+func (c *loadBalancerFile) validate() {
+	if strings.TrimSpace(c.Name) == "" {
+		exitOnError(fmt.Errorf("name is required"))
+	}
+	var err error
+	c.Method, err = validateLBMethod("method", c.Method)
+	exitOnError(err)
+	c.HealthCheck.Test, err = validateHCTest("health_check.test", c.HealthCheck.Test)
+	exitOnError(err)
+	if c.HealthCheck.Test == "HTTP" && strings.TrimSpace(c.HealthCheck.Path) == "" {
+		exitOnError(fmt.Errorf("health_check.path is required for the HTTP health check test"))
+	}
+	validateFileRange("health_check.interval", c.HealthCheck.Interval, 5, 300)
+	if c.Persistence {
+		validateFileRange("persistence_time", c.PersistenceTime, 30, 1200)
+	}
+	if len(c.Rules) == 0 {
+		exitOnError(fmt.Errorf("at least one entry in rules is required"))
+	}
+	for i := range c.Rules {
+		c.Rules[i].Protocol = strings.ToUpper(c.Rules[i].Protocol)
+		if c.Rules[i].Protocol != "TCP" && c.Rules[i].Protocol != "UDP" {
+			exitOnError(fmt.Errorf("invalid protocol of rule %d, valid values are TCP and UDP", i+1))
+		}
+		validateFileRange(fmt.Sprintf("port_balancer of rule %d", i+1), c.Rules[i].PortBalancer, 0, 65535)
+		validateFileRange(fmt.Sprintf("port_server of rule %d", i+1), c.Rules[i].PortServer, 0, 65535)
+	}
+}
+
+func (c *loadBalancerFile) request() *oneandone.LoadBalancerRequest {
+	req := &oneandone.LoadBalancerRequest{
+		Name:                  c.Name,
+		Description:           c.Description,
+		DatacenterId:          c.DatacenterId,
+		HealthCheckTest:       c.HealthCheck.Test,
+		HealthCheckInterval:   oneandone.Int2Pointer(c.HealthCheck.Interval),
+		HealthCheckPath:       c.HealthCheck.Path,
+		HealthCheckPathParser: c.HealthCheck.Parser,
+		Method:                c.Method,
+		Persistence:           oneandone.Bool2Pointer(c.Persistence),
+	}
+	if c.Persistence {
+		req.PersistenceTime = oneandone.Int2Pointer(c.PersistenceTime)
+	}
+	return req
+}
+
+func (c *loadBalancerFile) loadBalancerRules() []oneandone.LoadBalancerRule {
+	var rules []oneandone.LoadBalancerRule
+	for _, r := range c.Rules {
+		rules = append(rules, oneandone.LoadBalancerRule{
+			Protocol:     r.Protocol,
+			PortBalancer: uint16(r.PortBalancer),
+			PortServer:   uint16(r.PortServer),
+			Source:       r.Source,
+		})
+	}
+	return rules
+}
+
+// Resolves IDs of server IPs given only by address.
+func (c *loadBalancerFile) resolveServerIps() {
+	if c.ServerIps == nil {
+		return
+	}
+	var publicIps []oneandone.PublicIp
+	serverIps := *c.ServerIps
+	for i := range serverIps {
+		if serverIps[i].Id != "" {
+			continue
+		}
+		if serverIps[i].Ip == "" {
+			exitOnError(fmt.Errorf("entry %d of server_ips must have either id or ip", i+1))
+		}
+		if publicIps == nil {
+			var err error
+			publicIps, err = api.ListPublicIps()
+			exitOnError(err)
+		}
+		for _, ip := range publicIps {
+			if ip.IpAddress == serverIps[i].Ip {
+				serverIps[i].Id = ip.Id
+				break
+			}
+		}
+		if serverIps[i].Id == "" {
+			exitOnError(fmt.Errorf("IP address %s of server_ips not found", serverIps[i].Ip))
+		}
+	}
+}
+
+func exportLoadBalancer(ctx *cli.Context) {
+	lbId := getRequiredOption(ctx, "id")
+	lb, err := api.GetLoadBalancer(lbId)
+	exitOnError(err)
+	ips, err := api.ListLoadBalancerServerIps(lbId)
+	exitOnError(err)
+
+	content, err := yaml.Marshal(newLoadBalancerFile(lb, ips))
+	exitOnError(err)
+	if ctx.String("file") == "" {
+		fmt.Print(string(content))
+		return
+	}
+	exitOnError(ioutil.WriteFile(ctx.String("file"), content, 0644))
+	fmt.Printf("Load balancer configuration exported to %s\n", ctx.String("file"))
+}
+
+func applyLoadBalancer(ctx *cli.Context) {
+	fileName := getRequiredOption(ctx, "file")
+	content, err := ioutil.ReadFile(fileName)
+	exitOnError(err)
+	desired := new(loadBalancerFile)
+	exitOnError(yaml.Unmarshal(content, desired))
+	desired.validate()
+	desired.resolveServerIps()
+
+	lbId := ctx.String("id")
+	if lbId == "" {
+		lbs, err := api.ListLoadBalancers()
+		exitOnError(err)
+		for _, lb := range lbs {
+			if lb.Name == desired.Name {
+				lbId = lb.Id
+				break
+			}
+		}
+	}
+
+	if lbId == "" {
+		createLoadBalancerFromFile(ctx, desired)
+		return
+	}
+
+	current, err := api.GetLoadBalancer(lbId)
+	exitOnError(err)
+	currentIps, err := api.ListLoadBalancerServerIps(lbId)
+	exitOnError(err)
+	reconcileLoadBalancer(ctx, current, newLoadBalancerFile(current, currentIps), currentIps, desired)
+}
+
+func createLoadBalancerFromFile(ctx *cli.Context, desired *loadBalancerFile) {
+	fmt.Printf("Load balancer '%s' does not exist and will be created.\n", desired.Name)
+	if ctx.Bool("dryrun") {
+		return
+	}
+	req := desired.request()
+	req.Rules = desired.loadBalancerRules()
+	_, lb, err := api.CreateLoadBalancer(req)
+	exitOnError(err)
+
+	if desired.ServerIps != nil && len(*desired.ServerIps) > 0 {
+		ipIds := make([]string, len(*desired.ServerIps))
+		for i, ip := range *desired.ServerIps {
+			ipIds[i] = ip.Id
+		}
+		exitOnError(api.WaitForState(lb, "ACTIVE", 5, 60))
+		lb, err = api.AddLoadBalancerServerIps(lb.Id, ipIds)
+		exitOnError(err)
+	}
+	output(ctx, lb, okWaitMessage, false, nil, nil)
+}
+
+func reconcileLoadBalancer(ctx *cli.Context, lb *oneandone.LoadBalancer, current *loadBalancerFile,
+	currentIps []oneandone.ServerIpInfo, desired *loadBalancerFile) {
+	var changes []configChange
+	change := func(kind, field, from, to string) {
+		changes = append(changes, configChange{kind, field, from, to})
+	}
+
+	settingsChanged := false
+	compare := func(field, from, to string) {
+		if from != to {
+			change("~", field, from, to)
+			settingsChanged = true
+		}
+	}
+	compare("description", current.Description, desired.Description)
+	compare("method", current.Method, desired.Method)
+	compare("persistence", strconv.FormatBool(current.Persistence), strconv.FormatBool(desired.Persistence))
+	if desired.Persistence {
+		compare("persistence_time", strconv.Itoa(current.PersistenceTime), strconv.Itoa(desired.PersistenceTime))
+	}
+	compare("health_check.test", current.HealthCheck.Test, desired.HealthCheck.Test)
+	compare("health_check.interval", strconv.Itoa(current.HealthCheck.Interval), strconv.Itoa(desired.HealthCheck.Interval))
+	compare("health_check.path", current.HealthCheck.Path, desired.HealthCheck.Path)
+	compare("health_check.parser", current.HealthCheck.Parser, desired.HealthCheck.Parser)
+
+	wantedRules := make(map[string]bool)
+	for i := range desired.Rules {
+		wantedRules[desired.Rules[i].key()] = true
+	}
+	existingRules := make(map[string]bool)
+	var extraRules []string
+	for i, rule := range lb.Rules {
+		spec := &current.Rules[i]
+		existingRules[spec.key()] = true
+		if !wantedRules[spec.key()] {
+			extraRules = append(extraRules, rule.Id)
+			change("-", "rule", spec.String(), "")
+		}
+	}
+	var missingRules []oneandone.LoadBalancerRule
+	for i, rule := range desired.loadBalancerRules() {
+		if !existingRules[desired.Rules[i].key()] {
+			missingRules = append(missingRules, rule)
+			change("+", "rule", "", desired.Rules[i].String())
+		}
+	}
+
+	var extraIps, missingIps []string
+	if desired.ServerIps != nil {
+		wantedIps := make(map[string]bool)
+		for _, ip := range *desired.ServerIps {
+			wantedIps[ip.Id] = true
+		}
+		existingIps := make(map[string]bool)
+		for _, ip := range currentIps {
+			existingIps[ip.Id] = true
+			if !wantedIps[ip.Id] {
+				extraIps = append(extraIps, ip.Id)
+				change("-", "server_ip", ip.Ip+" "+ip.ServerName, "")
+			}
+		}
+		for _, ip := range *desired.ServerIps {
+			if !existingIps[ip.Id] {
+				missingIps = append(missingIps, ip.Id)
+				change("+", "server_ip", "", strings.TrimSpace(ip.Ip+" "+ip.ServerName))
+			}
+		}
+	}
+
+	if len(changes) == 0 {
+		fmt.Printf("Load balancer '%s' is up to date.\n", lb.Name)
+		return
+	}
+	outputConfigChanges(ctx, changes)
+	if ctx.Bool("dryrun") {
+		return
+	}
+
+	var err error
+	if settingsChanged {
+		req := desired.request()
+		req.DatacenterId = ""
+		exitOnError(api.WaitForState(lb, "ACTIVE", 5, 60))
+		_, err = api.UpdateLoadBalancer(lb.Id, req)
+		exitOnError(err)
+	}
+	if len(missingRules) > 0 {
+		exitOnError(api.WaitForState(lb, "ACTIVE", 5, 60))
+		_, err = api.AddLoadBalancerRules(lb.Id, missingRules)
+		exitOnError(err)
+	}
+	for _, ruleId := range extraRules {
+		exitOnError(api.WaitForState(lb, "ACTIVE", 5, 60))
+		_, err = api.DeleteLoadBalancerRule(lb.Id, ruleId)
+		exitOnError(err)
+	}
+	if len(missingIps) > 0 {
+		exitOnError(api.WaitForState(lb, "ACTIVE", 5, 60))
+		_, err = api.AddLoadBalancerServerIps(lb.Id, missingIps)
+		exitOnError(err)
+	}
+	for _, ipId := range extraIps {
+		exitOnError(api.WaitForState(lb, "ACTIVE", 5, 60))
+		_, err = api.DeleteLoadBalancerServerIp(lb.Id, ipId)
+		exitOnError(err)
+	}
+	fmt.Print(okWaitMessage)
+}
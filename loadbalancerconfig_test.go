@@ -0,0 +1,63 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/1and1/oneandone-cloudserver-sdk-go"
+	"gopkg.in/yaml.v2"
+)
+
+func TestLoadBalancerFileRoundTrip(t *testing.T) {
+	lb := &oneandone.LoadBalancer{
+		Name:                  "web",
+		Method:                "LEAST_CONNECTIONS",
+		HealthCheckTest:       "HTTP",
+		HealthCheckInterval:   30,
+		HealthCheckPath:       "/health",
+		HealthCheckPathParser: "^OK$",
+		Rules: []oneandone.LoadBalancerRule{
+			{Protocol: "TCP", PortBalancer: 80, PortServer: 8080, Source: "0.0.0.0"},
+		},
+	}
+	content, err := yaml.Marshal(newLoadBalancerFile(lb, nil))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	config := new(loadBalancerFile)
+	if err := yaml.Unmarshal(content, config); err != nil {
+		t.Fatal(err.Error())
+	}
+	config.validate()
+
+	req := config.request()
+	expected := &oneandone.LoadBalancerRequest{
+		Name:                  "web",
+		HealthCheckTest:       "HTTP",
+		HealthCheckInterval:   oneandone.Int2Pointer(30),
+		HealthCheckPath:       "/health",
+		HealthCheckPathParser: "^OK$",
+		Method:                "LEAST_CONNECTIONS",
+		Persistence:           oneandone.Bool2Pointer(false),
+	}
+	if !reflect.DeepEqual(expected, req) {
+		t.Errorf("expected request %+v, got %+v", expected, req)
+	}
+}
+
+func TestValidateLoadBalancerSettings(t *testing.T) {
+	if method, err := validateLBMethod("method", "rr"); err != nil || method != "ROUND_ROBIN" {
+		t.Errorf("expected ROUND_ROBIN for rr, got '%s', %v", method, err)
+	}
+	if _, err := validateLBMethod("method", "random"); err == nil ||
+		err.Error() != "Invalid value for method. Valid values are ROUND_ROBIN and LEAST_CONNECTIONS." {
+		t.Errorf("expected invalid method error, got %v", err)
+	}
+	if test, err := validateHCTest("health_check.test", "http"); err != nil || test != "HTTP" {
+		t.Errorf("expected HTTP for http, got '%s', %v", test, err)
+	}
+	if _, err := validateHCTest("health_check.test", "UDP"); err == nil ||
+		err.Error() != "Invalid value for health_check.test. Valid values are NONE, TCP, ICMP and HTTP." {
+		t.Errorf("expected invalid health check test error, got %v", err)
+	}
+}
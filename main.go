@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -125,6 +126,10 @@ func main() {
 	app.Commands = append(app.Commands, vpnOps...)
 	app.Commands = append(app.Commands, blockStorageOps...)
 	app.Commands = append(app.Commands, sshKeyOps...)
+	app.Commands = append(app.Commands, topOps...)
+	app.Commands = append(app.Commands, inventoryOps...)
+	app.Commands = append(app.Commands, sshOps...)
+	app.Commands = append(app.Commands, loginOps...)
 
 	app.Run(os.Args)
 }
@@ -172,8 +177,8 @@ Run '{{.Name}} COMMAND --help' for more information on a command.
 
 func beforeCommandRun(ctx *cli.Context) error {
 	if ctx.GlobalIsSet("about") {
-		fmt.Fprintf(os.Stdout, ctx.App.HelpName+"\n\n")
-		fmt.Fprintf(os.Stdout, appCopyright)
+		fmt.Fprint(os.Stdout, ctx.App.HelpName+"\n\n")
+		fmt.Fprint(os.Stdout, appCopyright)
 		fmt.Fprintf(os.Stdout, "\n\nThis software is using the following open source components:\n\n")
 		fmt.Fprintf(os.Stdout, "- codegangsta CLI framework\n")
 		fmt.Fprintf(os.Stdout, "\tCopyright (C) 2013 Jeremy Saenz\n")
@@ -182,11 +187,15 @@ func beforeCommandRun(ctx *cli.Context) error {
 		fmt.Fprintf(os.Stdout, "- ASCII Table Writer\n")
 		fmt.Fprintf(os.Stdout, "\tCopyright (C) 2014 by Oleku Konko\n")
 		fmt.Fprintf(os.Stdout, "\tLicense terms: https://github.com/olekukonko/tablewriter/blob/master/LICENCE.md\n")
+		fmt.Fprintf(os.Stdout, "\n- YAML support for the Go language\n")
+		fmt.Fprintf(os.Stdout, "\tCopyright (C) 2011-2016 Canonical Ltd.\n")
+		fmt.Fprintf(os.Stdout, "\tApache License 2.0: https://github.com/go-yaml/yaml/blob/v2/LICENSE\n")
 		os.Exit(0)
 	}
 	var err error
 
-	if ctx.NArg() > 1 {
+	// login and logout handle the API key themselves.
+	if cmd := ctx.Args().First(); ctx.NArg() > 1 && cmd != "login" && cmd != "logout" {
 		last := ctx.Args()[ctx.NArg()-1]
 		if last != "--help" && last != "-help" && last != "-h" && last != "--h" {
 			api, err = newClient(ctx.GlobalString("apikey"), ctx.GlobalString("baseurl"))
@@ -197,7 +206,13 @@ func beforeCommandRun(ctx *cli.Context) error {
 
 func newClient(token, url string) (*oneandone.API, error) {
 	if token == "" {
-		return nil, fmt.Errorf("No API key specified, use either --apikey global option or environment variable ONEANDONE_API_KEY")
+		var err error
+		if token, err = loadStoredApiKey(url); err != nil {
+			return nil, err
+		}
+	}
+	if token == "" {
+		return nil, fmt.Errorf("No API key specified, use either --apikey global option, environment variable ONEANDONE_API_KEY or 'oneandone login'")
 	}
 	if url == "" {
 		url = oneandone.BaseUrl
@@ -205,6 +220,16 @@ func newClient(token, url string) (*oneandone.API, error) {
 	return oneandone.New(token, url), nil
 }
 
+// Creates the API client for operations without commands, e.g. 'oneandone top',
+// for which beforeCommandRun does not set it up.
+func ensureClient(ctx *cli.Context) {
+	if api == nil {
+		var err error
+		api, err = newClient(ctx.GlobalString("apikey"), ctx.GlobalString("baseurl"))
+		exitOnError(err)
+	}
+}
+
 func getRequiredOption(ctx *cli.Context, flag string) string {
 	option := ctx.String(flag)
 	if !ctx.IsSet(flag) || strings.TrimSpace(option) == "" {
@@ -355,6 +380,14 @@ func exitOnError(err error) {
 	}
 }
 
+// Like exitOnError, but exits with the given status, for commands whose status is checked by scripts.
+func exitOnErrorStatus(err error, status int) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(status)
+	}
+}
+
 func output(ctx *cli.Context, in interface{}, m string, forceJson bool, header *[]string, data *[][]string) {
 	if forceJson || ctx.GlobalBool("json") {
 		bytes, _ := json.MarshalIndent(in, "", "    ")
@@ -370,6 +403,22 @@ func output(ctx *cli.Context, in interface{}, m string, forceJson bool, header *
 	fmt.Print(m)
 }
 
+// Returns the directory for files the CLI keeps between runs.
+func getConfigDir() string {
+	dir, err := os.UserConfigDir()
+	exitOnError(err)
+	return filepath.Join(dir, appName)
+}
+
+// Returns the directory for cached API data, or an empty string if the system has none.
+func getCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, appName)
+}
+
 func getDatacenter(dc *oneandone.Datacenter) string {
 	if dc != nil {
 		return dc.CountryCode
@@ -395,7 +444,7 @@ func getBsServer(server *oneandone.BlockStorageServer) string {
 func getSSHServers(servers []oneandone.SSHServer) string {
 	result := ""
 	for i, server := range servers {
-		result += string(i) + " - Id: " + server.Id + ", Name: " + server.Name + "\n"
+		result += strconv.Itoa(i) + " - Id: " + server.Id + ", Name: " + server.Name + "\n"
 	}
 	return result
 }
@@ -224,12 +224,42 @@ func init() {
 	mpCreateFlags := append(mpUpdateFlags, mpPortSliceFlags...)
 	mpCreateFlags = append(mpCreateFlags, mpProcessSliceFlags...)
 
+	mpFileFlag := cli.StringFlag{
+		Name:  "file, f",
+		Usage: "Path to the monitoring policy YAML file.",
+	}
+	mpTemplateFlag := cli.StringFlag{
+		Name:  "template",
+		Usage: "Name of the built-in monitoring policy template: web, database or windows.",
+	}
+	mpCreateFlags = append(mpCreateFlags, mpFileFlag, mpTemplateFlag)
+
 	monitorPolicyOps = []cli.Command{
 		{
 			Name:        "monitorpolicy",
 			Description: "1&1 monitoring policy operations",
 			Usage:       "Monitoring policy operations.",
 			Subcommands: []cli.Command{
+				{
+					Name:  "apply",
+					Usage: "Creates monitoring policy from YAML file or template or updates existing one to match it.",
+					Flags: []cli.Flag{
+						mpFileFlag,
+						mpTemplateFlag,
+						cli.StringFlag{
+							Name:  "id, i",
+							Usage: "ID of the monitoring policy to update. By default the policy is matched by name.",
+						},
+						mpNameFlag,
+						mpDescFlag,
+						mpEmailFlag,
+						cli.BoolFlag{
+							Name:  "dryrun",
+							Usage: "Only show the changes needed to apply the policy.",
+						},
+					},
+					Action: applyMonitorPolicy,
+				},
 				{
 					Name:  "assign",
 					Usage: "Assigns servers to monitoring policy.",
@@ -248,6 +278,18 @@ func init() {
 					Flags:  mpCreateFlags,
 					Action: createMonitorPolicy,
 				},
+				{
+					Name:  "export",
+					Usage: "Exports monitoring policy to YAML.",
+					Flags: []cli.Flag{
+						mpIdFlag,
+						cli.StringFlag{
+							Name:  "file, f",
+							Usage: "Path to the output file. Prints to standard output if not set.",
+						},
+					},
+					Action: exportMonitorPolicy,
+				},
 				{
 					Name:   "info",
 					Usage:  "Shows information about monitoring policy.",
@@ -338,6 +380,21 @@ func init() {
 					Flags:  []cli.Flag{mpIdFlag},
 					Action: deleteMonitorPolicy,
 				},
+				{
+					Name:  "templates",
+					Usage: "Lists built-in monitoring policy templates or shows one of them as YAML.",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "name, n",
+							Usage: "Name of the template to show.",
+						},
+						cli.StringFlag{
+							Name:  "file, f",
+							Usage: "Path to the output file for the template. Prints to standard output if not set.",
+						},
+					},
+					Action: listMonitorPolicyTemplates,
+				},
 				{
 					Name:   "unassign",
 					Usage:  "Unassigns servers from monitoring policy.",
@@ -560,6 +617,10 @@ func showMonitorPolicy(ctx *cli.Context) {
 }
 
 func createMonitorPolicy(ctx *cli.Context) {
+	if ctx.String("file") != "" || ctx.String("template") != "" {
+		createMonitorPolicyFromFile(ctx, loadMonitorPolicyFile(ctx))
+		return
+	}
 	_, monPolicy, err := api.CreateMonitoringPolicy(getRequest(ctx, true))
 	exitOnError(err)
 	output(ctx, monPolicy, okWaitMessage, false, nil, nil)
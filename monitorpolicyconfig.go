@@ -0,0 +1,572 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/1and1/oneandone-cloudserver-sdk-go"
+	"github.com/codegangsta/cli"
+	"gopkg.in/yaml.v2"
+)
+
+// Monitoring policy as it is written to and read from a YAML file.
+type monitorPolicyFile struct {
+	Name        string                `yaml:"name"`
+	Description string                `yaml:"description,omitempty"`
+	Email       string                `yaml:"email,omitempty"`
+	Agent       bool                  `yaml:"agent"`
+	Thresholds  *monitorThresholdSpec `yaml:"thresholds"`
+	Ports       []monitorPortSpec     `yaml:"ports,omitempty"`
+	Processes   []monitorProcessSpec  `yaml:"processes,omitempty"`
+}
+
+type monitorThresholdSpec struct {
+	Cpu          monitorLevelSpec `yaml:"cpu"`
+	Ram          monitorLevelSpec `yaml:"ram"`
+	Disk         monitorLevelSpec `yaml:"disk"`
+	Transfer     monitorLevelSpec `yaml:"transfer"`
+	InternalPing monitorLevelSpec `yaml:"internal_ping"`
+}
+
+type monitorLevelSpec struct {
+	Warning  monitorValueSpec `yaml:"warning"`
+	Critical monitorValueSpec `yaml:"critical"`
+}
+
+type monitorValueSpec struct {
+	Value int  `yaml:"value"`
+	Alert bool `yaml:"alert"`
+}
+
+type monitorPortSpec struct {
+	Port              int    `yaml:"port"`
+	Protocol          string `yaml:"protocol"`
+	AlertIf           string `yaml:"alert_if"`
+	EmailNotification bool   `yaml:"email_notification"`
+}
+
+type monitorProcessSpec struct {
+	Process           string `yaml:"process"`
+	AlertIf           string `yaml:"alert_if"`
+	EmailNotification bool   `yaml:"email_notification"`
+}
+
+type monitorPolicyTemplate struct {
+	description string
+	policy      monitorPolicyFile
+}
+
+func alertLevel(warning, critical int) monitorLevelSpec {
+	return monitorLevelSpec{
+		Warning:  monitorValueSpec{Value: warning, Alert: false},
+		Critical: monitorValueSpec{Value: critical, Alert: true},
+	}
+}
+
+// Built-in monitoring policy templates usable with --template.
+var monitorPolicyTemplates = map[string]monitorPolicyTemplate{
+	"web": {
+		description: "Web server with HTTP and HTTPS ports",
+		policy: monitorPolicyFile{
+			Name:  "Web server",
+			Agent: true,
+			Thresholds: &monitorThresholdSpec{
+				Cpu:          alertLevel(90, 95),
+				Ram:          alertLevel(90, 95),
+				Disk:         alertLevel(80, 90),
+				Transfer:     alertLevel(1000, 2000),
+				InternalPing: alertLevel(50, 100),
+			},
+			Ports: []monitorPortSpec{
+				{Port: 80, Protocol: "TCP", AlertIf: "NOT_RESPONDING", EmailNotification: true},
+				{Port: 443, Protocol: "TCP", AlertIf: "NOT_RESPONDING", EmailNotification: true},
+			},
+		},
+	},
+	"database": {
+		description: "MySQL or MariaDB database server",
+		policy: monitorPolicyFile{
+			Name:  "Database server",
+			Agent: true,
+			Thresholds: &monitorThresholdSpec{
+				Cpu:          alertLevel(85, 95),
+				Ram:          alertLevel(85, 95),
+				Disk:         alertLevel(75, 90),
+				Transfer:     alertLevel(1000, 2000),
+				InternalPing: alertLevel(20, 50),
+			},
+			Ports: []monitorPortSpec{
+				{Port: 3306, Protocol: "TCP", AlertIf: "NOT_RESPONDING", EmailNotification: true},
+			},
+			Processes: []monitorProcessSpec{
+				{Process: "mysqld", AlertIf: "NOT_RUNNING", EmailNotification: true},
+			},
+		},
+	},
+	"windows": {
+		description: "Windows server with remote desktop",
+		policy: monitorPolicyFile{
+			Name:  "Windows server",
+			Agent: true,
+			Thresholds: &monitorThresholdSpec{
+				Cpu:          alertLevel(90, 95),
+				Ram:          alertLevel(90, 95),
+				Disk:         alertLevel(80, 90),
+				Transfer:     alertLevel(1000, 2000),
+				InternalPing: alertLevel(50, 100),
+			},
+			Ports: []monitorPortSpec{
+				{Port: 3389, Protocol: "TCP", AlertIf: "NOT_RESPONDING", EmailNotification: true},
+			},
+			Processes: []monitorProcessSpec{
+				{Process: "svchost.exe", AlertIf: "NOT_RUNNING", EmailNotification: false},
+			},
+		},
+	},
+}
+
+func (p *monitorPortSpec) key() string {
+	return fmt.Sprintf("%s/%d", p.Protocol, p.Port)
+}
+
+func (p *monitorPortSpec) String() string {
+	return fmt.Sprintf("%s alert if %s, notify %t", p.key(), p.AlertIf, p.EmailNotification)
+}
+
+func (p *monitorProcessSpec) String() string {
+	return fmt.Sprintf("%s alert if %s, notify %t", p.Process, p.AlertIf, p.EmailNotification)
+}
+
+func newMonitorLevelSpec(level *oneandone.MonitoringLevel) monitorLevelSpec {
+	var spec monitorLevelSpec
+	if level == nil {
+		return spec
+	}
+	if level.Warning != nil {
+		spec.Warning = monitorValueSpec{Value: level.Warning.Value, Alert: level.Warning.Alert}
+	}
+	if level.Critical != nil {
+		spec.Critical = monitorValueSpec{Value: level.Critical.Value, Alert: level.Critical.Alert}
+	}
+	return spec
+}
+
+func (l *monitorLevelSpec) level() *oneandone.MonitoringLevel {
+	return &oneandone.MonitoringLevel{
+		Warning:  &oneandone.MonitoringValue{Value: l.Warning.Value, Alert: l.Warning.Alert},
+		Critical: &oneandone.MonitoringValue{Value: l.Critical.Value, Alert: l.Critical.Alert},
+	}
+}
+
+func newMonitorPolicyFile(mp *oneandone.MonitoringPolicy) *monitorPolicyFile {
+	config := &monitorPolicyFile{
+		Name:        mp.Name,
+		Description: mp.Description,
+		Email:       mp.Email,
+		Agent:       mp.Agent,
+	}
+	config.Thresholds = &monitorThresholdSpec{}
+	if mp.Thresholds != nil {
+		config.Thresholds = &monitorThresholdSpec{
+			Cpu:          newMonitorLevelSpec(mp.Thresholds.Cpu),
+			Ram:          newMonitorLevelSpec(mp.Thresholds.Ram),
+			Disk:         newMonitorLevelSpec(mp.Thresholds.Disk),
+			Transfer:     newMonitorLevelSpec(mp.Thresholds.Transfer),
+			InternalPing: newMonitorLevelSpec(mp.Thresholds.InternalPing),
+		}
+	}
+	for _, port := range mp.Ports {
+		config.Ports = append(config.Ports, monitorPortSpec{
+			Port:              port.Port,
+			Protocol:          port.Protocol,
+			AlertIf:           port.AlertIf,
+			EmailNotification: port.EmailNotification,
+		})
+	}
+	for _, process := range mp.Processes {
+		config.Processes = append(config.Processes, monitorProcessSpec{
+			Process:           process.Process,
+			AlertIf:           process.AlertIf,
+			EmailNotification: process.EmailNotification,
+		})
+	}
+	return config
+}
+
+// Validates the policy the same way as the flags of 'monitorpolicy create'.
+func (c *monitorPolicyFile) validate() {
+	if strings.TrimSpace(c.Name) == "" {
+		exitOnError(fmt.Errorf("monitoring policy name is required"))
+	}
+	if strings.TrimSpace(c.Email) == "" {
+		exitOnError(fmt.Errorf("monitoring policy e-mail is required, use --email to set it"))
+	}
+	if c.Thresholds == nil {
+		exitOnError(fmt.Errorf("thresholds are required"))
+	}
+	c.Thresholds.validate()
+	for i := range c.Ports {
+		c.Ports[i].Protocol = verifyPortProtocol(c.Ports[i].Protocol)
+		c.Ports[i].AlertIf = verifyPortAlert(c.Ports[i].AlertIf)
+		validateFileRange(fmt.Sprintf("ports[%d].port", i), c.Ports[i].Port, 1, 65535)
+	}
+	for i := range c.Processes {
+		if strings.TrimSpace(c.Processes[i].Process) == "" {
+			exitOnError(fmt.Errorf("name of process %d is required", i+1))
+		}
+		c.Processes[i].AlertIf = verifyProcessAlert(c.Processes[i].AlertIf)
+	}
+}
+
+// Checks the thresholds against the ranges of the --cpuwv, --cpucv, ... flags
+// and that each warning value is lower than its critical value.
+func (t *monitorThresholdSpec) validate() {
+	levels := []struct {
+		name        string
+		level       monitorLevelSpec
+		maxWarning  int
+		maxCritical int
+	}{
+		{"cpu", t.Cpu, 95, 100},
+		{"ram", t.Ram, 95, 100},
+		{"disk", t.Disk, 95, 100},
+		{"transfer", t.Transfer, 2000, 2000},
+		{"internal_ping", t.InternalPing, 100, 100},
+	}
+	for _, l := range levels {
+		warning := "thresholds." + l.name + ".warning.value"
+		critical := "thresholds." + l.name + ".critical.value"
+		validateFileRange(warning, l.level.Warning.Value, 1, l.maxWarning)
+		validateFileRange(critical, l.level.Critical.Value, 1, l.maxCritical)
+		if l.level.Warning.Value >= l.level.Critical.Value {
+			exitOnError(fmt.Errorf("%s must be lower than %s, got %d and %d",
+				warning, critical, l.level.Warning.Value, l.level.Critical.Value))
+		}
+	}
+}
+
+func (c *monitorPolicyFile) thresholds() *oneandone.MonitoringThreshold {
+	return &oneandone.MonitoringThreshold{
+		Cpu:          c.Thresholds.Cpu.level(),
+		Ram:          c.Thresholds.Ram.level(),
+		Disk:         c.Thresholds.Disk.level(),
+		Transfer:     c.Thresholds.Transfer.level(),
+		InternalPing: c.Thresholds.InternalPing.level(),
+	}
+}
+
+func (c *monitorPolicyFile) monitoringPorts() []oneandone.MonitoringPort {
+	var ports []oneandone.MonitoringPort
+	for _, p := range c.Ports {
+		ports = append(ports, oneandone.MonitoringPort{
+			Port:              p.Port,
+			Protocol:          p.Protocol,
+			AlertIf:           p.AlertIf,
+			EmailNotification: p.EmailNotification,
+		})
+	}
+	return ports
+}
+
+func (c *monitorPolicyFile) monitoringProcesses() []oneandone.MonitoringProcess {
+	var processes []oneandone.MonitoringProcess
+	for _, p := range c.Processes {
+		processes = append(processes, oneandone.MonitoringProcess{
+			Process:           p.Process,
+			AlertIf:           p.AlertIf,
+			EmailNotification: p.EmailNotification,
+		})
+	}
+	return processes
+}
+
+// Reads the policy given by --file or --template. The --name, --desc and --email
+// flags take precedence over the values in the file.
+func loadMonitorPolicyFile(ctx *cli.Context) *monitorPolicyFile {
+	config := new(monitorPolicyFile)
+	fileName, template := ctx.String("file"), ctx.String("template")
+	switch {
+	case fileName != "" && template != "":
+		exitOnError(fmt.Errorf("--file and --template cannot be used together"))
+	case fileName != "":
+		content, err := ioutil.ReadFile(fileName)
+		exitOnError(err)
+		exitOnError(yaml.Unmarshal(content, config))
+	case template != "":
+		config = getMonitorPolicyTemplate(template)
+	default:
+		exitOnError(fmt.Errorf("missing required --file or --template option"))
+	}
+	if ctx.IsSet("name") {
+		config.Name = ctx.String("name")
+	}
+	if ctx.IsSet("desc") {
+		config.Description = ctx.String("desc")
+	}
+	if ctx.IsSet("email") {
+		config.Email = ctx.String("email")
+	}
+	config.validate()
+	return config
+}
+
+func getMonitorPolicyTemplate(name string) *monitorPolicyFile {
+	template, ok := monitorPolicyTemplates[strings.ToLower(name)]
+	if !ok {
+		exitOnError(fmt.Errorf("unknown monitoring policy template '%s', valid templates are %s",
+			name, strings.Join(monitorPolicyTemplateNames(), ", ")))
+	}
+	config := template.policy
+	config.Ports = append([]monitorPortSpec(nil), template.policy.Ports...)
+	config.Processes = append([]monitorProcessSpec(nil), template.policy.Processes...)
+	thresholds := *template.policy.Thresholds
+	config.Thresholds = &thresholds
+	return &config
+}
+
+func monitorPolicyTemplateNames() []string {
+	var names []string
+	for name := range monitorPolicyTemplates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func writeMonitorPolicyFile(ctx *cli.Context, config *monitorPolicyFile) {
+	content, err := yaml.Marshal(config)
+	exitOnError(err)
+	if ctx.String("file") == "" {
+		fmt.Print(string(content))
+		return
+	}
+	exitOnError(ioutil.WriteFile(ctx.String("file"), content, 0644))
+	fmt.Printf("Monitoring policy exported to %s\n", ctx.String("file"))
+}
+
+func listMonitorPolicyTemplates(ctx *cli.Context) {
+	if ctx.String("name") != "" {
+		writeMonitorPolicyFile(ctx, getMonitorPolicyTemplate(ctx.String("name")))
+		return
+	}
+	names := monitorPolicyTemplateNames()
+	data := make([][]string, len(names))
+	for i, name := range names {
+		template := monitorPolicyTemplates[name]
+		var ports, processes []string
+		for _, p := range template.policy.Ports {
+			ports = append(ports, p.key())
+		}
+		for _, p := range template.policy.Processes {
+			processes = append(processes, p.Process)
+		}
+		data[i] = []string{name, template.description, strings.Join(ports, ", "), strings.Join(processes, ", ")}
+	}
+	header := []string{"Name", "Description", "Ports", "Processes"}
+	output(ctx, names, "", false, &header, &data)
+}
+
+func exportMonitorPolicy(ctx *cli.Context) {
+	mpId := getRequiredOption(ctx, "id")
+	monPolicy, err := api.GetMonitoringPolicy(mpId)
+	exitOnError(err)
+	writeMonitorPolicyFile(ctx, newMonitorPolicyFile(monPolicy))
+}
+
+func createMonitorPolicyFromFile(ctx *cli.Context, config *monitorPolicyFile) {
+	mp := &oneandone.MonitoringPolicy{
+		Name:        config.Name,
+		Description: config.Description,
+		Email:       config.Email,
+		Agent:       config.Agent,
+		Thresholds:  config.thresholds(),
+		Ports:       config.monitoringPorts(),
+		Processes:   config.monitoringProcesses(),
+	}
+	_, monPolicy, err := api.CreateMonitoringPolicy(mp)
+	exitOnError(err)
+	output(ctx, monPolicy, okWaitMessage, false, nil, nil)
+}
+
+func applyMonitorPolicy(ctx *cli.Context) {
+	desired := loadMonitorPolicyFile(ctx)
+
+	mpId := ctx.String("id")
+	if mpId == "" {
+		policies, err := api.ListMonitoringPolicies()
+		exitOnError(err)
+		for _, policy := range policies {
+			if policy.Name == desired.Name {
+				mpId = policy.Id
+				break
+			}
+		}
+	}
+	if mpId == "" {
+		fmt.Printf("Monitoring policy '%s' does not exist and will be created.\n", desired.Name)
+		if !ctx.Bool("dryrun") {
+			createMonitorPolicyFromFile(ctx, desired)
+		}
+		return
+	}
+
+	monPolicy, err := api.GetMonitoringPolicy(mpId)
+	exitOnError(err)
+	syncMonitorPolicy(ctx, monPolicy, desired)
+}
+
+func syncMonitorPolicy(ctx *cli.Context, monPolicy *oneandone.MonitoringPolicy, desired *monitorPolicyFile) {
+	current := newMonitorPolicyFile(monPolicy)
+	var changes []configChange
+	change := func(kind, field, from, to string) {
+		changes = append(changes, configChange{kind, field, from, to})
+	}
+
+	settingsChanged := false
+	compare := func(field, from, to string) {
+		if from != to {
+			change("~", field, from, to)
+			settingsChanged = true
+		}
+	}
+	compare("description", current.Description, desired.Description)
+	compare("email", current.Email, desired.Email)
+	compare("agent", strconv.FormatBool(current.Agent), strconv.FormatBool(desired.Agent))
+	levels := []struct {
+		name             string
+		current, desired monitorLevelSpec
+	}{
+		{"cpu", current.Thresholds.Cpu, desired.Thresholds.Cpu},
+		{"ram", current.Thresholds.Ram, desired.Thresholds.Ram},
+		{"disk", current.Thresholds.Disk, desired.Thresholds.Disk},
+		{"transfer", current.Thresholds.Transfer, desired.Thresholds.Transfer},
+		{"internal_ping", current.Thresholds.InternalPing, desired.Thresholds.InternalPing},
+	}
+	for _, l := range levels {
+		field := "thresholds." + l.name
+		compare(field+".warning.value", strconv.Itoa(l.current.Warning.Value), strconv.Itoa(l.desired.Warning.Value))
+		compare(field+".warning.alert", strconv.FormatBool(l.current.Warning.Alert), strconv.FormatBool(l.desired.Warning.Alert))
+		compare(field+".critical.value", strconv.Itoa(l.current.Critical.Value), strconv.Itoa(l.desired.Critical.Value))
+		compare(field+".critical.alert", strconv.FormatBool(l.current.Critical.Alert), strconv.FormatBool(l.desired.Critical.Alert))
+	}
+
+	wantedPorts := make(map[string]*monitorPortSpec)
+	for i := range desired.Ports {
+		wantedPorts[desired.Ports[i].key()] = &desired.Ports[i]
+	}
+	existingPorts := make(map[string]bool)
+	modifiedPorts := make(map[string]*oneandone.MonitoringPort)
+	var extraPorts []string
+	for i, port := range monPolicy.Ports {
+		spec := &current.Ports[i]
+		existingPorts[spec.key()] = true
+		wanted, ok := wantedPorts[spec.key()]
+		if !ok {
+			extraPorts = append(extraPorts, port.Id)
+			change("-", "port", spec.String(), "")
+		} else if wanted.AlertIf != spec.AlertIf || wanted.EmailNotification != spec.EmailNotification {
+			modifiedPorts[port.Id] = &oneandone.MonitoringPort{
+				Port:              wanted.Port,
+				Protocol:          wanted.Protocol,
+				AlertIf:           wanted.AlertIf,
+				EmailNotification: wanted.EmailNotification,
+			}
+			change("~", "port", spec.String(), wanted.String())
+		}
+	}
+	var missingPorts []oneandone.MonitoringPort
+	for i, port := range desired.monitoringPorts() {
+		if !existingPorts[desired.Ports[i].key()] {
+			missingPorts = append(missingPorts, port)
+			change("+", "port", "", desired.Ports[i].String())
+		}
+	}
+
+	wantedProcesses := make(map[string]*monitorProcessSpec)
+	for i := range desired.Processes {
+		wantedProcesses[desired.Processes[i].Process] = &desired.Processes[i]
+	}
+	existingProcesses := make(map[string]bool)
+	modifiedProcesses := make(map[string]*oneandone.MonitoringProcess)
+	var extraProcesses []string
+	for i, process := range monPolicy.Processes {
+		spec := &current.Processes[i]
+		existingProcesses[spec.Process] = true
+		wanted, ok := wantedProcesses[spec.Process]
+		if !ok {
+			extraProcesses = append(extraProcesses, process.Id)
+			change("-", "process", spec.String(), "")
+		} else if wanted.AlertIf != spec.AlertIf || wanted.EmailNotification != spec.EmailNotification {
+			modifiedProcesses[process.Id] = &oneandone.MonitoringProcess{
+				Process:           wanted.Process,
+				AlertIf:           wanted.AlertIf,
+				EmailNotification: wanted.EmailNotification,
+			}
+			change("~", "process", spec.String(), wanted.String())
+		}
+	}
+	var missingProcesses []oneandone.MonitoringProcess
+	for i, process := range desired.monitoringProcesses() {
+		if !existingProcesses[desired.Processes[i].Process] {
+			missingProcesses = append(missingProcesses, process)
+			change("+", "process", "", desired.Processes[i].String())
+		}
+	}
+
+	if len(changes) == 0 {
+		fmt.Printf("Monitoring policy '%s' is up to date.\n", monPolicy.Name)
+		return
+	}
+	outputConfigChanges(ctx, changes)
+	if ctx.Bool("dryrun") {
+		return
+	}
+
+	var err error
+	mpId := monPolicy.Id
+	if settingsChanged {
+		exitOnError(api.WaitForState(monPolicy, "ACTIVE", 5, 60))
+		_, err = api.UpdateMonitoringPolicy(mpId, &oneandone.MonitoringPolicy{
+			Name:        desired.Name,
+			Description: desired.Description,
+			Email:       desired.Email,
+			Agent:       desired.Agent,
+			Thresholds:  desired.thresholds(),
+		})
+		exitOnError(err)
+	}
+	for portId, port := range modifiedPorts {
+		exitOnError(api.WaitForState(monPolicy, "ACTIVE", 5, 60))
+		_, err = api.ModifyMonitoringPolicyPort(mpId, portId, port)
+		exitOnError(err)
+	}
+	if len(missingPorts) > 0 {
+		exitOnError(api.WaitForState(monPolicy, "ACTIVE", 5, 60))
+		_, err = api.AddMonitoringPolicyPorts(mpId, missingPorts)
+		exitOnError(err)
+	}
+	for _, portId := range extraPorts {
+		exitOnError(api.WaitForState(monPolicy, "ACTIVE", 5, 60))
+		_, err = api.DeleteMonitoringPolicyPort(mpId, portId)
+		exitOnError(err)
+	}
+	for processId, process := range modifiedProcesses {
+		exitOnError(api.WaitForState(monPolicy, "ACTIVE", 5, 60))
+		_, err = api.ModifyMonitoringPolicyProcess(mpId, processId, process)
+		exitOnError(err)
+	}
+	if len(missingProcesses) > 0 {
+		exitOnError(api.WaitForState(monPolicy, "ACTIVE", 5, 60))
+		_, err = api.AddMonitoringPolicyProcesses(mpId, missingProcesses)
+		exitOnError(err)
+	}
+	for _, processId := range extraProcesses {
+		exitOnError(api.WaitForState(monPolicy, "ACTIVE", 5, 60))
+		_, err = api.DeleteMonitoringPolicyProcess(mpId, processId)
+		exitOnError(err)
+	}
+	fmt.Print(okWaitMessage)
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"fmt"
+
 	"github.com/1and1/oneandone-cloudserver-sdk-go"
 	"github.com/codegangsta/cli"
 )
@@ -22,7 +24,7 @@ func init() {
 	}
 	pnAddressFlag := cli.StringFlag{
 		Name:  "netip",
-		Usage: "Private network IP address.",
+		Usage: "Private network IP address. CIDR notation such as 192.168.1.0/24 may be used instead of --netmask.",
 	}
 	pnMaskFlag := cli.StringFlag{
 		Name:  "netmask",
@@ -37,7 +39,17 @@ func init() {
 		Usage: "Data center ID of the private network.",
 	}
 
-	pnCreateFlags := []cli.Flag{dcIdFlag, pnDescFlag, pnNameFlag, pnAddressFlag, pnMaskFlag}
+	pnCreateFlags := []cli.Flag{
+		dcIdFlag,
+		pnDescFlag,
+		pnNameFlag,
+		pnAddressFlag,
+		pnMaskFlag,
+		cli.StringFlag{
+			Name:  "auto-cidr",
+			Usage: "Size of the subnet to pick automatically, e.g. /24, instead of --netip and --netmask.",
+		},
+	}
 
 	privateNetOps = []cli.Command{
 		{
@@ -63,6 +75,28 @@ func init() {
 					Flags:  pnCreateFlags,
 					Action: createPrivateNet,
 				},
+				{
+					Name:  "guestconfig",
+					Usage: "Generates network configuration of private interface for guest OS of server.",
+					Flags: []cli.Flag{
+						pnIdFlag,
+						pnServerIdFlag,
+						cli.StringFlag{
+							Name:  "format",
+							Usage: "Configuration format: " + guestConfigFormats + ".",
+						},
+						cli.StringFlag{
+							Name:  "interface",
+							Value: "eth1",
+							Usage: "Name of the private network interface in the guest OS.",
+						},
+						cli.StringFlag{
+							Name:  "ip",
+							Usage: "Host address to assign to the server instead of the next free one.",
+						},
+					},
+					Action: generateGuestConfig,
+				},
 				{
 					Name:   "info",
 					Usage:  "Shows information about private network.",
@@ -87,6 +121,19 @@ func init() {
 					Flags:  []cli.Flag{pnIdFlag},
 					Action: listPrivateNetServers,
 				},
+				{
+					Name:  "plan",
+					Usage: "Lists private network subnets, detects conflicts and suggests next free subnet.",
+					Flags: []cli.Flag{
+						dcIdFlag,
+						cli.StringFlag{
+							Name:  "size",
+							Value: "/24",
+							Usage: "Size of the suggested free subnet.",
+						},
+					},
+					Action: planPrivateNets,
+				},
 				{
 					Name:   "rm",
 					Usage:  "Removes private network.",
@@ -136,12 +183,20 @@ func showPrivateNet(ctx *cli.Context) {
 }
 
 func createPrivateNet(ctx *cli.Context) {
+	name := getRequiredOption(ctx, "name")
+	address, mask := getPrivateNetAddress(ctx)
+	if ctx.String("auto-cidr") != "" {
+		if address != "" || mask != "" {
+			exitOnError(fmt.Errorf("--auto-cidr cannot be used together with --netip or --netmask"))
+		}
+		address, mask = autoPrivateNetAddress(ctx.String("auto-cidr"), ctx.String("datacenterid"))
+	}
 	req := oneandone.PrivateNetworkRequest{
-		Name:           getRequiredOption(ctx, "name"),
+		Name:           name,
 		DatacenterId:   ctx.String("datacenterid"),
 		Description:    ctx.String("desc"),
-		NetworkAddress: ctx.String("netip"),
-		SubnetMask:     ctx.String("netmask"),
+		NetworkAddress: address,
+		SubnetMask:     mask,
 	}
 	_, privateNet, err := api.CreatePrivateNetwork(&req)
 	exitOnError(err)
@@ -150,11 +205,12 @@ func createPrivateNet(ctx *cli.Context) {
 
 func updatePrivateNet(ctx *cli.Context) {
 	pnId := getRequiredOption(ctx, "id")
+	address, mask := getPrivateNetAddress(ctx)
 	req := oneandone.PrivateNetworkRequest{
 		Name:           ctx.String("name"),
 		Description:    ctx.String("desc"),
-		NetworkAddress: ctx.String("netip"),
-		SubnetMask:     ctx.String("netmask"),
+		NetworkAddress: address,
+		SubnetMask:     mask,
 	}
 	privateNet, err := api.UpdatePrivateNetwork(pnId, &req)
 	exitOnError(err)
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/1and1/oneandone-cloudserver-sdk-go"
+	"github.com/codegangsta/cli"
+)
+
+const (
+	privateNetLedgerFile = "privatenet-addresses.json"
+	guestConfigFormats   = "netplan, ifupdown, networkmanager or cloud-init"
+)
+
+// Host addresses handed out to servers of private networks, keyed by network ID.
+type privateNetLedger map[string]*privateNetAllocation
+
+type privateNetAllocation struct {
+	Cidr    string            `json:"cidr"`
+	Servers map[string]string `json:"servers"`
+}
+
+func privateNetLedgerPath() string {
+	return filepath.Join(getConfigDir(), privateNetLedgerFile)
+}
+
+func loadPrivateNetLedger() privateNetLedger {
+	ledger := make(privateNetLedger)
+	content, err := ioutil.ReadFile(privateNetLedgerPath())
+	if os.IsNotExist(err) {
+		return ledger
+	}
+	exitOnError(err)
+	exitOnError(json.Unmarshal(content, &ledger))
+	return ledger
+}
+
+func (l privateNetLedger) save() {
+	content, err := json.MarshalIndent(l, "", "    ")
+	exitOnError(err)
+	exitOnError(os.MkdirAll(getConfigDir(), 0700))
+	exitOnError(ioutil.WriteFile(privateNetLedgerPath(), content, 0600))
+}
+
+// Assigns host addresses to the servers of a private network. Addresses reported by
+// the API and addresses recorded in the ledger are kept, the remaining servers get the
+// lowest free host addresses in the order they are attached to the network.
+func allocatePrivateNetAddresses(allocation *privateNetAllocation, network *net.IPNet,
+	servers []oneandone.Identity, reported map[string]string, pinned map[string]string) error {
+	attached := make(map[string]bool)
+	for _, server := range servers {
+		attached[server.Id] = true
+	}
+	for serverId := range allocation.Servers {
+		if !attached[serverId] {
+			delete(allocation.Servers, serverId)
+		}
+	}
+	// Addresses reported by the API and pinned with --ip take precedence over the ledger.
+	fixed := make(map[string]string)
+	for serverId, ip := range reported {
+		fixed[serverId] = ip
+	}
+	for serverId, ip := range pinned {
+		addr := net.ParseIP(ip).To4()
+		if addr == nil || !network.Contains(addr) || addr.Equal(network.IP) ||
+			ipToUint(addr) == lastAddress(network) {
+			return fmt.Errorf("%s is not a host address in %s", ip, network)
+		}
+		fixed[serverId] = addr.String()
+	}
+
+	used := make(map[string]string)
+	for _, server := range servers {
+		if ip, ok := fixed[server.Id]; ok {
+			if other, taken := used[ip]; taken {
+				return fmt.Errorf("%s is already used by server %s", ip, other)
+			}
+			used[ip] = server.Id
+			allocation.Servers[server.Id] = ip
+		}
+	}
+	for _, server := range servers {
+		ip, ok := allocation.Servers[server.Id]
+		if !ok || used[ip] == server.Id {
+			continue
+		}
+		if _, taken := used[ip]; taken {
+			delete(allocation.Servers, server.Id)
+			continue
+		}
+		used[ip] = server.Id
+	}
+
+	next := ipToUint(network.IP) + 1
+	last := lastAddress(network)
+	for _, server := range servers {
+		if _, ok := allocation.Servers[server.Id]; ok {
+			continue
+		}
+		for next < last {
+			if _, taken := used[uintToIp(next).String()]; !taken {
+				break
+			}
+			next++
+		}
+		if next >= last {
+			return fmt.Errorf("no free host address left in %s", network)
+		}
+		ip := uintToIp(next).String()
+		allocation.Servers[server.Id] = ip
+		used[ip] = server.Id
+	}
+	return nil
+}
+
+// Returns the private IPs reported by the API for servers of the network.
+func getReportedPrivateIps(pnId string) map[string]string {
+	servers, err := api.ListServers()
+	exitOnError(err)
+	reported := make(map[string]string)
+	for _, server := range servers {
+		for _, pn := range server.PrivateNets {
+			if pn.Id == pnId && pn.ServerIP != "" {
+				reported[server.Id] = pn.ServerIP
+			}
+		}
+	}
+	return reported
+}
+
+func generateGuestConfig(ctx *cli.Context) {
+	pnId := getRequiredOption(ctx, "id")
+	serverId := getRequiredOption(ctx, "serverid")
+	format := strings.ToLower(getRequiredOption(ctx, "format"))
+	iface := ctx.String("interface")
+	switch format {
+	case "netplan", "ifupdown", "networkmanager", "cloud-init":
+	default:
+		exitOnError(fmt.Errorf("--format must be either %s", guestConfigFormats))
+	}
+
+	pNet, err := api.GetPrivateNetwork(pnId)
+	exitOnError(err)
+	if pNet.NetworkAddress == "" || pNet.SubnetMask == "" {
+		exitOnError(fmt.Errorf("private network %s has no network address", pNet.Name))
+	}
+	network, err := parsePrivateNetwork(pNet.NetworkAddress, pNet.SubnetMask)
+	exitOnError(err)
+
+	servers, err := api.ListPrivateNetworkServers(pnId)
+	exitOnError(err)
+	found := false
+	for _, server := range servers {
+		if server.Id == serverId {
+			found = true
+		}
+	}
+	if !found {
+		exitOnError(fmt.Errorf("server %s is not attached to private network %s", serverId, pNet.Name))
+	}
+
+	ledger := loadPrivateNetLedger()
+	allocation := ledger[pnId]
+	if allocation == nil || allocation.Cidr != network.String() {
+		allocation = &privateNetAllocation{Cidr: network.String(), Servers: make(map[string]string)}
+		ledger[pnId] = allocation
+	}
+	pinned := make(map[string]string)
+	if ctx.String("ip") != "" {
+		pinned[serverId] = ctx.String("ip")
+	}
+	exitOnError(allocatePrivateNetAddresses(allocation, network, servers, getReportedPrivateIps(pnId), pinned))
+	ledger.save()
+
+	ones, _ := network.Mask.Size()
+	address := allocation.Servers[serverId]
+	cidr := fmt.Sprintf("%s/%d", address, ones)
+	mask := net.IP(network.Mask).String()
+
+	var config string
+	switch format {
+	case "netplan":
+		config = fmt.Sprintf("# /etc/netplan/60-%s.yaml\n"+
+			"network:\n  version: 2\n  renderer: networkd\n  ethernets:\n    %s:\n"+
+			"      dhcp4: false\n      addresses:\n        - %s\n", iface, iface, cidr)
+	case "ifupdown":
+		config = fmt.Sprintf("# /etc/network/interfaces.d/%s\n"+
+			"auto %s\niface %s inet static\n    address %s\n    netmask %s\n", iface, iface, iface, address, mask)
+	case "networkmanager":
+		config = fmt.Sprintf("# /etc/NetworkManager/system-connections/%s.nmconnection\n"+
+			"[connection]\nid=%s\ntype=ethernet\ninterface-name=%s\n\n"+
+			"[ipv4]\nmethod=manual\naddress1=%s\n\n[ipv6]\nmethod=ignore\n", iface, pNet.Name, iface, cidr)
+	default:
+		config = fmt.Sprintf("# cloud-init network-config\n"+
+			"network:\n  version: 2\n  ethernets:\n    %s:\n"+
+			"      dhcp4: false\n      addresses:\n        - %s\n", iface, cidr)
+	}
+	fmt.Print(config)
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/1and1/oneandone-cloudserver-sdk-go"
+	"github.com/codegangsta/cli"
+)
+
+// Private address ranges searched for free subnets, in order of preference.
+var privateNetPools = []string{"192.168.0.0/16", "172.16.0.0/12", "10.0.0.0/8"}
+
+type privateNetPlanEntry struct {
+	Id           string   `json:"id"`
+	Name         string   `json:"name"`
+	DatacenterId string   `json:"datacenter_id,omitempty"`
+	Datacenter   string   `json:"datacenter,omitempty"`
+	Cidr         string   `json:"cidr,omitempty"`
+	Conflicts    []string `json:"conflicts,omitempty"`
+	network      *net.IPNet
+}
+
+type privateNetPlan struct {
+	Networks []*privateNetPlanEntry `json:"networks"`
+	NextFree map[string]string      `json:"next_free"`
+}
+
+// Parses the network address and subnet mask of a private network.
+func parsePrivateNetwork(address, mask string) (*net.IPNet, error) {
+	ip := net.ParseIP(address).To4()
+	if ip == nil {
+		return nil, fmt.Errorf("invalid network address '%s'", address)
+	}
+	maskIp := net.ParseIP(mask).To4()
+	if maskIp == nil {
+		return nil, fmt.Errorf("invalid subnet mask '%s'", mask)
+	}
+	ipMask := net.IPMask(maskIp)
+	if _, bits := ipMask.Size(); bits == 0 {
+		return nil, fmt.Errorf("invalid subnet mask '%s'", mask)
+	}
+	return &net.IPNet{IP: ip.Mask(ipMask), Mask: ipMask}, nil
+}
+
+// Returns the network address and subnet mask given by the --netip and --netmask flags.
+// --netip also accepts CIDR notation, e.g. 192.168.10.0/24, in which case --netmask must not be set.
+func getPrivateNetAddress(ctx *cli.Context) (string, string) {
+	address, mask := ctx.String("netip"), ctx.String("netmask")
+	if !strings.Contains(address, "/") {
+		return address, mask
+	}
+	if mask != "" {
+		exitOnError(fmt.Errorf("--netmask cannot be used when --netip is given in CIDR notation"))
+	}
+	ip, network, err := net.ParseCIDR(address)
+	exitOnError(err)
+	if ip.To4() == nil {
+		exitOnError(fmt.Errorf("only IPv4 private networks are supported"))
+	}
+	if !ip.Equal(network.IP) {
+		exitOnError(fmt.Errorf("%s is not a network address, did you mean %s?", address, network.String()))
+	}
+	return network.IP.String(), net.IP(network.Mask).String()
+}
+
+// Parses a prefix length given as '/24' or '24'.
+func parsePrefixLength(flag, value string) int {
+	prefix, err := strconv.Atoi(strings.TrimPrefix(value, "/"))
+	if err != nil {
+		exitOnError(fmt.Errorf("--%s must be a prefix length such as /24", flag))
+	}
+	return validateIntRange(flag, prefix, 8, 30)
+}
+
+func networksOverlap(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+func ipToUint(ip net.IP) uint32 {
+	return binary.BigEndian.Uint32(ip.To4())
+}
+
+func uintToIp(n uint32) net.IP {
+	ip := make(net.IP, 4)
+	binary.BigEndian.PutUint32(ip, n)
+	return ip
+}
+
+func lastAddress(network *net.IPNet) uint32 {
+	return ipToUint(network.IP) | ^binary.BigEndian.Uint32(network.Mask)
+}
+
+// Finds the first subnet of the given prefix length in the private ranges that
+// does not overlap any of the used networks.
+func nextFreeSubnet(used []*net.IPNet, prefix int) (*net.IPNet, error) {
+	mask := net.CIDRMask(prefix, 32)
+	size := uint64(1) << uint(32-prefix)
+	for _, cidr := range privateNetPools {
+		_, pool, _ := net.ParseCIDR(cidr)
+		if ones, _ := pool.Mask.Size(); ones > prefix {
+			continue
+		}
+		end := uint64(lastAddress(pool))
+		for start := uint64(ipToUint(pool.IP)); start+size-1 <= end; {
+			candidate := &net.IPNet{IP: uintToIp(uint32(start)), Mask: mask}
+			next := start
+			for _, u := range used {
+				if networksOverlap(candidate, u) && uint64(lastAddress(u))+1 > next {
+					next = uint64(lastAddress(u)) + 1
+				}
+			}
+			if next == start {
+				return candidate, nil
+			}
+			// Continue at the next aligned subnet after the overlapping networks.
+			start = (next + size - 1) / size * size
+		}
+	}
+	return nil, fmt.Errorf("no free /%d subnet left in private address ranges", prefix)
+}
+
+func newPrivateNetPlanEntries(pNets []oneandone.PrivateNetwork) []*privateNetPlanEntry {
+	entries := make([]*privateNetPlanEntry, len(pNets))
+	for i, pn := range pNets {
+		entry := &privateNetPlanEntry{Id: pn.Id, Name: pn.Name, Datacenter: getDatacenter(pn.Datacenter)}
+		if pn.Datacenter != nil {
+			entry.DatacenterId = pn.Datacenter.Id
+		}
+		if pn.NetworkAddress != "" || pn.SubnetMask != "" {
+			network, err := parsePrivateNetwork(pn.NetworkAddress, pn.SubnetMask)
+			if err != nil {
+				entry.Conflicts = append(entry.Conflicts, err.Error())
+			} else {
+				entry.network = network
+				entry.Cidr = network.String()
+			}
+		}
+		entries[i] = entry
+	}
+
+	for i, a := range entries {
+		for j, b := range entries {
+			if i == j || a.DatacenterId != b.DatacenterId || a.network == nil || b.network == nil {
+				continue
+			}
+			if a.Cidr == b.Cidr {
+				a.Conflicts = append(a.Conflicts, "same subnet as "+b.Name)
+			} else if networksOverlap(a.network, b.network) {
+				a.Conflicts = append(a.Conflicts, "overlaps "+b.Name+" ("+b.Cidr+")")
+			}
+		}
+	}
+	return entries
+}
+
+func usedNetworks(entries []*privateNetPlanEntry, datacenterId string) []*net.IPNet {
+	var used []*net.IPNet
+	for _, e := range entries {
+		if e.network != nil && (datacenterId == "" || e.DatacenterId == datacenterId) {
+			used = append(used, e.network)
+		}
+	}
+	return used
+}
+
+func planPrivateNets(ctx *cli.Context) {
+	prefix := parsePrefixLength("size", ctx.String("size"))
+	pNets, err := api.ListPrivateNetworks()
+	exitOnError(err)
+	entries := newPrivateNetPlanEntries(pNets)
+	if dcId := ctx.String("datacenterid"); dcId != "" {
+		var filtered []*privateNetPlanEntry
+		for _, e := range entries {
+			if e.DatacenterId == dcId {
+				filtered = append(filtered, e)
+			}
+		}
+		entries = filtered
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Datacenter != entries[j].Datacenter {
+			return entries[i].Datacenter < entries[j].Datacenter
+		}
+		if entries[i].network == nil || entries[j].network == nil {
+			return entries[j].network == nil && entries[i].network != nil
+		}
+		return ipToUint(entries[i].network.IP) < ipToUint(entries[j].network.IP)
+	})
+
+	plan := privateNetPlan{Networks: entries, NextFree: make(map[string]string)}
+	var datacenters []string
+	dcNames := make(map[string]string)
+	conflicts := 0
+	data := make([][]string, len(entries))
+	for i, e := range entries {
+		if _, ok := dcNames[e.DatacenterId]; !ok {
+			dcNames[e.DatacenterId] = e.Datacenter
+			datacenters = append(datacenters, e.DatacenterId)
+		}
+		if len(e.Conflicts) > 0 {
+			conflicts++
+		}
+		data[i] = []string{e.Id, e.Name, e.Datacenter, e.Cidr, strings.Join(e.Conflicts, "; ")}
+	}
+	if len(datacenters) == 0 {
+		datacenters = append(datacenters, ctx.String("datacenterid"))
+	}
+
+	var message string
+	for _, dcId := range datacenters {
+		free, err := nextFreeSubnet(usedNetworks(entries, dcId), prefix)
+		exitOnError(err)
+		plan.NextFree[dcId] = free.String()
+		name := dcNames[dcId]
+		if name == "" {
+			name = dcId
+		}
+		if name == "" {
+			message += fmt.Sprintf("Next free /%d subnet: %s\n", prefix, free)
+		} else {
+			message += fmt.Sprintf("Next free /%d subnet in %s: %s\n", prefix, name, free)
+		}
+	}
+	message += fmt.Sprintf("%d of %d private networks have conflicts.\n", conflicts, len(entries))
+
+	header := []string{"ID", "Name", "Data Center", "Network", "Conflicts"}
+	output(ctx, plan, message, false, &header, &data)
+}
+
+// Picks a free subnet of the given size for a new private network in the data center.
+// Without a data center, the networks of all data centers are avoided.
+func autoPrivateNetAddress(size, datacenterId string) (string, string) {
+	prefix := parsePrefixLength("auto-cidr", size)
+	pNets, err := api.ListPrivateNetworks()
+	exitOnError(err)
+	free, err := nextFreeSubnet(usedNetworks(newPrivateNetPlanEntries(pNets), datacenterId), prefix)
+	exitOnError(err)
+	return free.IP.String(), net.IP(free.Mask).String()
+}
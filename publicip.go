@@ -50,6 +50,56 @@ func init() {
 					Flags:  queryFlags,
 					Action: listIPs,
 				},
+				{
+					Name:  "rdns",
+					Usage: "Reverse DNS operations on public IPs.",
+					Subcommands: []cli.Command{
+						{
+							Name:   "list",
+							Usage:  "Lists reverse DNS names of public IPs.",
+							Action: listRdns,
+						},
+						{
+							Name:   "export",
+							Usage:  "Exports reverse DNS names of public IPs as BIND reverse zone.",
+							Action: exportRdns,
+							Flags: []cli.Flag{
+								cli.StringFlag{
+									Name:  "file, f",
+									Usage: "Path to the output file. Prints to standard output if not set.",
+								},
+								cli.IntFlag{
+									Name:  "ttl",
+									Value: 3600,
+									Usage: "Default TTL of the records in seconds.",
+								},
+							},
+						},
+						{
+							Name:   "sync",
+							Usage:  "Sets reverse DNS names of public IPs from forward records in CSV or BIND zone file.",
+							Action: syncRdns,
+							Flags: []cli.Flag{
+								cli.StringFlag{
+									Name:  "file, f",
+									Usage: "Path to the CSV or zone file with host names and IP addresses.",
+								},
+								cli.StringFlag{
+									Name:  "format",
+									Usage: "File format: csv or zone. Determined by the file extension if not set.",
+								},
+								cli.StringFlag{
+									Name:  "origin",
+									Usage: "Origin of relative names in a zone file without $ORIGIN.",
+								},
+								cli.BoolFlag{
+									Name:  "dryrun",
+									Usage: "Only show the reverse DNS changes.",
+								},
+							},
+						},
+					},
+				},
 				{
 					Name:   "rm",
 					Usage:  "Deletes public IP.",
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/1and1/oneandone-cloudserver-sdk-go"
+	"github.com/codegangsta/cli"
+)
+
+// Forward DNS record mapping a host name to an IP address.
+type dnsRecord struct {
+	Name string
+	Ip   string
+}
+
+type rdnsChange struct {
+	Id      string `json:"id"`
+	Ip      string `json:"ip"`
+	Current string `json:"current"`
+	Desired string `json:"desired"`
+}
+
+// Returns the canonical form of an IP address or an empty string if it is not valid.
+func canonicalIp(address string) string {
+	ip := net.ParseIP(strings.TrimSpace(address))
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+func getRdnsFormat(ctx *cli.Context, fileName string) string {
+	format := strings.ToLower(ctx.String("format"))
+	if format == "" {
+		if strings.ToLower(filepath.Ext(fileName)) == ".csv" {
+			format = "csv"
+		} else {
+			format = "zone"
+		}
+	}
+	if format != "csv" && format != "zone" {
+		exitOnError(fmt.Errorf("--format must be either csv or zone"))
+	}
+	return format
+}
+
+// Reads records from CSV lines of host name and IP address, in any column order.
+// Lines without an IP address, such as a header, are skipped.
+func readRdnsCsv(r io.Reader) ([]dnsRecord, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	var records []dnsRecord
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) < 2 {
+			continue
+		}
+		name, ip := strings.TrimSpace(fields[0]), canonicalIp(fields[1])
+		if ip == "" {
+			name, ip = strings.TrimSpace(fields[1]), canonicalIp(fields[0])
+		}
+		if ip == "" || name == "" {
+			continue
+		}
+		records = append(records, dnsRecord{Name: strings.TrimSuffix(name, "."), Ip: ip})
+	}
+	return records, nil
+}
+
+// Reads A and AAAA records from a BIND zone file. $ORIGIN, relative names, '@' and
+// owner names inherited from the previous record are resolved, other record types are ignored.
+// Relative names of A and AAAA records are an error without an origin.
+func readRdnsZone(r io.Reader, origin string) ([]dnsRecord, error) {
+	var records []dnsRecord
+	origin = strings.TrimSuffix(origin, ".")
+	owner, ownerErr := absoluteDnsName("@", origin)
+	depth := 0
+	lineNo := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if i := strings.Index(line, ";"); i >= 0 {
+			line = line[:i]
+		}
+		// Skip the continuation lines of multi-line records such as SOA.
+		inParens := depth > 0
+		depth += strings.Count(line, "(") - strings.Count(line, ")")
+		if inParens || strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if strings.HasPrefix(fields[0], "$") {
+			if strings.ToUpper(fields[0]) == "$ORIGIN" && len(fields) > 1 {
+				origin = strings.TrimSuffix(fields[1], ".")
+			}
+			continue
+		}
+		if line[0] != ' ' && line[0] != '\t' {
+			owner, ownerErr = absoluteDnsName(fields[0], origin)
+			fields = fields[1:]
+		}
+		// Skip optional TTL and class before the record type.
+		for len(fields) > 0 && (isDnsTtl(fields[0]) || isDnsClass(fields[0])) {
+			fields = fields[1:]
+		}
+		if len(fields) < 2 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "A", "AAAA":
+			if ip := canonicalIp(fields[1]); ip != "" {
+				if ownerErr != nil {
+					return nil, fmt.Errorf("line %d: %s", lineNo, ownerErr.Error())
+				}
+				records = append(records, dnsRecord{Name: owner, Ip: ip})
+			}
+		}
+	}
+	return records, scanner.Err()
+}
+
+func absoluteDnsName(name, origin string) (string, error) {
+	switch {
+	case strings.HasSuffix(name, "."):
+		return strings.TrimSuffix(name, "."), nil
+	case origin == "":
+		return "", fmt.Errorf("relative name '%s' cannot be resolved without $ORIGIN or --origin", name)
+	case name == "@":
+		return origin, nil
+	}
+	return name + "." + origin, nil
+}
+
+func isDnsTtl(field string) bool {
+	if field == "" {
+		return false
+	}
+	for _, c := range strings.ToLower(field) {
+		if (c < '0' || c > '9') && !strings.ContainsRune("smhdw", c) {
+			return false
+		}
+	}
+	return field[0] >= '0' && field[0] <= '9'
+}
+
+func isDnsClass(field string) bool {
+	switch strings.ToUpper(field) {
+	case "IN", "CH", "HS":
+		return true
+	}
+	return false
+}
+
+// Returns the name of the PTR record of an IP address, e.g. 4.3.2.1.in-addr.arpa for 1.2.3.4.
+func reverseDnsName(address string) string {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return ""
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa", ip4[3], ip4[2], ip4[1], ip4[0])
+	}
+	const hexDigits = "0123456789abcdef"
+	ip16 := ip.To16()
+	nibbles := make([]string, 0, 32)
+	for i := len(ip16) - 1; i >= 0; i-- {
+		nibbles = append(nibbles, string(hexDigits[ip16[i]&0xf]), string(hexDigits[ip16[i]>>4]))
+	}
+	return strings.Join(nibbles, ".") + ".ip6.arpa"
+}
+
+func listRdns(ctx *cli.Context) {
+	ips, err := api.ListPublicIps()
+	exitOnError(err)
+	data := make([][]string, len(ips))
+	for i, ip := range ips {
+		data[i] = []string{ip.Id, ip.IpAddress, ip.ReverseDns}
+	}
+	header := []string{"ID", "IP Address", "Reverse DNS"}
+	output(ctx, ips, "", false, &header, &data)
+}
+
+func syncRdns(ctx *cli.Context) {
+	fileName := getRequiredOption(ctx, "file")
+	format := getRdnsFormat(ctx, fileName)
+	file, err := os.Open(fileName)
+	exitOnError(err)
+	defer file.Close()
+
+	var records []dnsRecord
+	if format == "csv" {
+		records, err = readRdnsCsv(file)
+	} else {
+		records, err = readRdnsZone(file, ctx.String("origin"))
+	}
+	exitOnError(err)
+
+	desired := make(map[string]string)
+	var warnings string
+	for _, r := range records {
+		if name, ok := desired[r.Ip]; ok && name != r.Name {
+			warnings += fmt.Sprintf("%s has several names, using %s and ignoring %s\n", r.Ip, name, r.Name)
+			continue
+		}
+		desired[r.Ip] = r.Name
+	}
+
+	ips, err := api.ListPublicIps()
+	exitOnError(err)
+	var changes []rdnsChange
+	matched := 0
+	for _, ip := range ips {
+		name, ok := desired[canonicalIp(ip.IpAddress)]
+		if !ok {
+			continue
+		}
+		matched++
+		if strings.TrimSuffix(ip.ReverseDns, ".") != name {
+			changes = append(changes, rdnsChange{Id: ip.Id, Ip: ip.IpAddress, Current: ip.ReverseDns, Desired: name})
+		}
+	}
+
+	message := warnings + fmt.Sprintf("%d of %d addresses in the file match a public IP, %d reverse DNS name(s) to change.\n",
+		matched, len(desired), len(changes))
+	data := make([][]string, len(changes))
+	for i, c := range changes {
+		data[i] = []string{c.Id, c.Ip, c.Current, c.Desired}
+	}
+	header := []string{"ID", "IP Address", "Current", "Desired"}
+	output(ctx, changes, message, false, &header, &data)
+	if ctx.Bool("dryrun") {
+		return
+	}
+
+	for _, c := range changes {
+		_, err := api.UpdatePublicIp(c.Id, c.Desired)
+		exitOnError(err)
+	}
+	if len(changes) > 0 {
+		fmt.Print(okWaitMessage)
+	}
+}
+
+func exportRdns(ctx *cli.Context) {
+	ips, err := api.ListPublicIps()
+	exitOnError(err)
+
+	var ipv4, ipv6 []oneandone.PublicIp
+	for _, ip := range ips {
+		if ip.ReverseDns == "" || net.ParseIP(ip.IpAddress) == nil {
+			continue
+		}
+		if net.ParseIP(ip.IpAddress).To4() != nil {
+			ipv4 = append(ipv4, ip)
+		} else {
+			ipv6 = append(ipv6, ip)
+		}
+	}
+
+	var out io.Writer = os.Stdout
+	if ctx.String("file") != "" {
+		file, err := os.Create(ctx.String("file"))
+		exitOnError(err)
+		defer file.Close()
+		out = file
+	}
+	w := bufio.NewWriter(out)
+	fmt.Fprintf(w, "; Reverse DNS records of public IPs\n$TTL %d\n", ctx.Int("ttl"))
+	for _, section := range []struct {
+		title string
+		ips   []oneandone.PublicIp
+	}{{"IPv4", ipv4}, {"IPv6", ipv6}} {
+		if len(section.ips) == 0 {
+			continue
+		}
+		sort.Slice(section.ips, func(i, j int) bool {
+			return reverseDnsName(section.ips[i].IpAddress) < reverseDnsName(section.ips[j].IpAddress)
+		})
+		fmt.Fprintf(w, "\n; %s\n", section.title)
+		for _, ip := range section.ips {
+			fmt.Fprintf(w, "%s.\tIN\tPTR\t%s.\t; %s\n",
+				reverseDnsName(ip.IpAddress), strings.TrimSuffix(ip.ReverseDns, "."), ip.IpAddress)
+		}
+	}
+	exitOnError(w.Flush())
+	if ctx.String("file") != "" {
+		fmt.Printf("%d reverse DNS records exported to %s\n", len(ipv4)+len(ipv6), ctx.String("file"))
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadRdnsZone(t *testing.T) {
+	zone := `$TTL 3600
+@	IN	SOA	ns1.example.com. admin.example.com. (
+		2024010101 ; serial
+		3600 )
+	IN	NS	ns1.example.com.
+@	IN	A	1.2.3.4
+www	300	IN	A	1.2.3.5
+	IN	AAAA	2001:db8::1
+mail.example.org.	IN	A	1.2.3.6
+`
+	tests := []struct {
+		in, origin string
+		records    []dnsRecord
+		err        string
+	}{
+		{zone, "example.com.", []dnsRecord{
+			{"example.com", "1.2.3.4"},
+			{"www.example.com", "1.2.3.5"},
+			{"www.example.com", "2001:db8::1"},
+			{"mail.example.org", "1.2.3.6"},
+		}, ""},
+		{"$ORIGIN example.net.\nhost A 10.0.0.1\n", "", []dnsRecord{{"host.example.net", "10.0.0.1"}}, ""},
+		{"mail.example.org. A 1.2.3.6\n", "", []dnsRecord{{"mail.example.org", "1.2.3.6"}}, ""},
+		{zone, "", nil, "line 6: relative name '@' cannot be resolved without $ORIGIN or --origin"},
+		{"www A 1.2.3.5\n", "", nil, "line 1: relative name 'www' cannot be resolved without $ORIGIN or --origin"},
+	}
+	for _, test := range tests {
+		records, err := readRdnsZone(strings.NewReader(test.in), test.origin)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%q: expected error '%s', got %v", test.in, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %s", test.in, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(test.records, records) {
+			t.Errorf("%q: expected records %+v, got %+v", test.in, test.records, records)
+		}
+	}
+}
@@ -233,6 +233,17 @@ func init() {
 					},
 					Action: createRole,
 				},
+				{
+					Name:  "diff",
+					Usage: "Shows permission differences between two roles.",
+					Flags: []cli.Flag{
+						cli.StringSliceFlag{
+							Name:  "id, i",
+							Usage: "IDs of the two roles to compare.",
+						},
+					},
+					Action: diffRoles,
+				},
 				{
 					Name:   "info",
 					Usage:  "Shows information about role.",
@@ -245,6 +256,21 @@ func init() {
 					Flags:  queryFlags,
 					Action: listRoles,
 				},
+				{
+					Name:  "matrix",
+					Usage: "Shows permissions of one or more roles side by side.",
+					Flags: []cli.Flag{
+						cli.StringSliceFlag{
+							Name:  "id, i",
+							Usage: "List of role IDs.",
+						},
+						cli.StringSliceFlag{
+							Name:  "resource",
+							Usage: "Show only permissions of the resource, e.g. servers. Can be repeated.",
+						},
+					},
+					Action: showRoleMatrix,
+				},
 				{
 					Name:  "modify",
 					Usage: "Modifies role configuration.",
@@ -287,6 +313,18 @@ func init() {
 							},
 							Action: modifyPerm,
 						},
+						{
+							Name:  "export",
+							Usage: "Exports role's permissions to YAML.",
+							Flags: []cli.Flag{
+								roleIdFlag,
+								cli.StringFlag{
+									Name:  "file, f",
+									Usage: "Path to the output file. Prints to standard output if not set.",
+								},
+							},
+							Action: exportRolePermissions,
+						},
 						{
 							Name:   fw_info,
 							Usage:  "Shows permissions for firewall policies.",
@@ -331,6 +369,22 @@ func init() {
 							},
 							Action: modifyPerm,
 						},
+						{
+							Name:  "import",
+							Usage: "Applies permissions from a YAML file to the role.",
+							Flags: []cli.Flag{
+								roleIdFlag,
+								cli.StringFlag{
+									Name:  "file, f",
+									Usage: "Path to the YAML file with the permissions.",
+								},
+								cli.BoolFlag{
+									Name:  "dryrun",
+									Usage: "Only show the changes needed to apply the file.",
+								},
+							},
+							Action: importRolePermissions,
+						},
 						{
 							Name:   "info",
 							Usage:  "Shows all permissions.",
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/1and1/oneandone-cloudserver-sdk-go"
+	"github.com/codegangsta/cli"
+	"gopkg.in/yaml.v2"
+)
+
+// Permissions of a role by resource and action, using the names of the API, e.g. servers/create.
+type permissionMatrix map[string]map[string]bool
+
+// Role permissions as exported to YAML.
+type rolePermissionsFile struct {
+	Role        string           `yaml:"role,omitempty"`
+	Permissions permissionMatrix `yaml:"permissions"`
+}
+
+func newPermissionMatrix(perms *oneandone.Permissions) permissionMatrix {
+	content, err := json.Marshal(perms)
+	exitOnError(err)
+	matrix := make(permissionMatrix)
+	exitOnError(json.Unmarshal(content, &matrix))
+	return matrix
+}
+
+// Returns the matrix of all resources and actions a role can be granted.
+func allPermissions() permissionMatrix {
+	perms := new(oneandone.Permissions)
+	perms.SetAll(true)
+	return newPermissionMatrix(perms)
+}
+
+func (m permissionMatrix) permissions() *oneandone.Permissions {
+	content, err := json.Marshal(m)
+	exitOnError(err)
+	perms := new(oneandone.Permissions)
+	exitOnError(json.Unmarshal(content, perms))
+	return perms
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (m permissionMatrix) resources() []string {
+	names := make([]string, 0, len(m))
+	for r := range m {
+		names = append(names, r)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Checks that the matrix only contains resources and actions known to the API.
+func (m permissionMatrix) validate() error {
+	all := allPermissions()
+	for resource, actions := range m {
+		known, ok := all[resource]
+		if !ok {
+			return fmt.Errorf("unknown resource '%s', valid resources are %s", resource, strings.Join(all.resources(), ", "))
+		}
+		for action := range actions {
+			if !known[action] {
+				return fmt.Errorf("unknown action '%s' of %s, valid actions are %s", action, resource, strings.Join(sortedKeys(known), ", "))
+			}
+		}
+	}
+	return nil
+}
+
+type rolePermissions struct {
+	Role   *oneandone.Role  `json:"role"`
+	Matrix permissionMatrix `json:"permissions"`
+}
+
+func getRolePermissions(ids []string) []rolePermissions {
+	roles := make([]rolePermissions, len(ids))
+	for i, id := range ids {
+		role, err := api.GetRole(id)
+		exitOnError(err)
+		perms, err := api.GetRolePermissions(id)
+		exitOnError(err)
+		roles[i] = rolePermissions{Role: role, Matrix: newPermissionMatrix(perms)}
+	}
+	return roles
+}
+
+func permissionMark(granted bool) string {
+	if granted {
+		return "x"
+	}
+	return ""
+}
+
+func showRoleMatrix(ctx *cli.Context) {
+	roles := getRolePermissions(getStringSliceOption(ctx, "id", true))
+	filter := make(map[string]bool)
+	for _, r := range ctx.StringSlice("resource") {
+		filter[r] = true
+	}
+
+	all := allPermissions()
+	header := []string{"Resource", "Action"}
+	for _, r := range roles {
+		header = append(header, r.Role.Name)
+	}
+	var data [][]string
+	for _, resource := range all.resources() {
+		if len(filter) > 0 && !filter[resource] {
+			continue
+		}
+		for _, action := range sortedKeys(all[resource]) {
+			row := []string{resource, action}
+			for _, r := range roles {
+				row = append(row, permissionMark(r.Matrix[resource][action]))
+			}
+			data = append(data, row)
+		}
+	}
+	output(ctx, roles, "", false, &header, &data)
+}
+
+func diffRoles(ctx *cli.Context) {
+	ids := getStringSliceOption(ctx, "id", true)
+	if len(ids) != 2 {
+		exitOnError(fmt.Errorf("exactly two --id options must be specified"))
+	}
+	roles := getRolePermissions(ids)
+	first, second := roles[0].Matrix, roles[1].Matrix
+
+	type permissionDiff struct {
+		Resource string `json:"resource"`
+		Action   string `json:"action"`
+		First    bool   `json:"first"`
+		Second   bool   `json:"second"`
+	}
+	var diff []permissionDiff
+	all := allPermissions()
+	for _, resource := range all.resources() {
+		for _, action := range sortedKeys(all[resource]) {
+			if first[resource][action] != second[resource][action] {
+				diff = append(diff, permissionDiff{resource, action, first[resource][action], second[resource][action]})
+			}
+		}
+	}
+	if len(diff) == 0 {
+		fmt.Printf("Roles '%s' and '%s' have the same permissions.\n", roles[0].Role.Name, roles[1].Role.Name)
+		return
+	}
+	data := make([][]string, len(diff))
+	for i, d := range diff {
+		data[i] = []string{d.Resource, d.Action, permissionMark(d.First), permissionMark(d.Second)}
+	}
+	header := []string{"Resource", "Action", roles[0].Role.Name, roles[1].Role.Name}
+	output(ctx, diff, "", false, &header, &data)
+}
+
+func exportRolePermissions(ctx *cli.Context) {
+	role := getRolePermissions([]string{getRequiredOption(ctx, "id")})[0]
+	content, err := yaml.Marshal(&rolePermissionsFile{Role: role.Role.Name, Permissions: role.Matrix})
+	exitOnError(err)
+	if ctx.String("file") == "" {
+		fmt.Print(string(content))
+		return
+	}
+	exitOnError(ioutil.WriteFile(ctx.String("file"), content, 0644))
+	fmt.Printf("Role permissions exported to %s\n", ctx.String("file"))
+}
+
+// Applies the permissions of the file to the role. Resources and actions not listed in the file are kept.
+func importRolePermissions(ctx *cli.Context) {
+	id := getRequiredOption(ctx, "id")
+	fileName := getRequiredOption(ctx, "file")
+	content, err := ioutil.ReadFile(fileName)
+	exitOnError(err)
+	var config rolePermissionsFile
+	exitOnError(yaml.UnmarshalStrict(content, &config))
+	if err := config.Permissions.validate(); err != nil {
+		exitOnError(fmt.Errorf("%s: %v", fileName, err))
+	}
+
+	role := getRolePermissions([]string{id})[0]
+	changed := make(permissionMatrix)
+	var changes []configChange
+	for _, resource := range config.Permissions.resources() {
+		for _, action := range sortedKeys(config.Permissions[resource]) {
+			current, desired := role.Matrix[resource][action], config.Permissions[resource][action]
+			if current == desired {
+				continue
+			}
+			changes = append(changes, configChange{"~", resource + "." + action, permissionMark(current), permissionMark(desired)})
+			if changed[resource] == nil {
+				changed[resource] = make(map[string]bool)
+				for a, v := range role.Matrix[resource] {
+					changed[resource][a] = v
+				}
+			}
+			changed[resource][action] = desired
+		}
+	}
+
+	if len(changes) == 0 {
+		fmt.Printf("Permissions of role '%s' are up to date.\n", role.Role.Name)
+		return
+	}
+	outputConfigChanges(ctx, changes)
+	if ctx.Bool("dryrun") {
+		return
+	}
+	updated, err := api.ModifyRolePermissions(id, changed.permissions())
+	exitOnError(err)
+	output(ctx, updated, "OK", false, nil, nil)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/1and1/oneandone-cloudserver-sdk-go"
+	"github.com/codegangsta/cli"
+)
+
+const (
+	passwordUpper   = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+	passwordLower   = "abcdefghijkmnopqrstuvwxyz"
+	passwordDigits  = "23456789"
+	passwordSymbols = "!#%+-.=@_"
+)
+
+// Returns the secret flag together with the flags for reading it from stdin, a file or a prompt,
+// for generating it and for choosing where generated secrets are stored.
+func secretFlags(flag cli.StringFlag) []cli.Flag {
+	name := strings.TrimSpace(strings.Split(flag.Name, ",")[0])
+	return []cli.Flag{
+		flag,
+		cli.BoolFlag{
+			Name:  name + "-stdin",
+			Usage: "Read the " + name + " from the first line of standard input.",
+		},
+		cli.StringFlag{
+			Name:  name + "-file",
+			Usage: "Read the " + name + " from the file.",
+		},
+		cli.BoolFlag{
+			Name:  name + "-prompt",
+			Usage: "Prompt for the " + name + " without echo.",
+		},
+		cli.BoolFlag{
+			Name:  "generate-password",
+			Usage: "Generate a random password meeting the API complexity rules.",
+		},
+		cli.StringFlag{
+			Name:  "secrets-file",
+			Usage: "File to store generated passwords in with 0600 permissions. Defaults to a file in the configuration directory.",
+		},
+	}
+}
+
+// Returns the secret given by one of the flags created by secretFlags. The secret is prompted for
+// without echo with the prompt flag, or without any of the flags if it is required and stdin is a terminal.
+// Generated is true if the secret was generated and must be stored with saveSecret.
+func getSecret(ctx *cli.Context, name string, required bool) (secret string, generated bool) {
+	sources := 0
+	prompt := ctx.Bool(name + "-prompt")
+	for _, set := range []bool{ctx.IsSet(name), ctx.Bool(name + "-stdin"), ctx.String(name+"-file") != "", prompt, ctx.Bool("generate-password")} {
+		if set {
+			sources++
+		}
+	}
+	if sources > 1 {
+		exitOnError(fmt.Errorf("only one of --%s, --%s-stdin, --%s-file, --%s-prompt and --generate-password can be used",
+			name, name, name, name))
+	}
+
+	switch {
+	case ctx.IsSet(name):
+		fmt.Fprintf(os.Stderr, "Warning: --%s exposes the secret in the shell history and process list, "+
+			"use --%s-stdin or --%s-file instead.\n", name, name, name)
+		secret = ctx.String(name)
+	case ctx.Bool(name + "-stdin"):
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			exitOnError(fmt.Errorf("failed to read the %s from standard input: %v", name, err))
+		}
+		secret = strings.TrimRight(line, "\r\n")
+	case ctx.String(name+"-file") != "":
+		content, err := ioutil.ReadFile(ctx.String(name + "-file"))
+		exitOnError(err)
+		secret = strings.TrimRight(strings.SplitN(string(content), "\n", 2)[0], "\r")
+	case ctx.Bool("generate-password"):
+		return generatePassword(16), true
+	case prompt && !isTerminal(os.Stdin):
+		exitOnError(fmt.Errorf("--%s-prompt requires standard input to be a terminal", name))
+	case prompt || required && isTerminal(os.Stdin):
+		secret = promptSecret(strings.Title(name) + ": ")
+		if promptSecret("Repeat "+name+": ") != secret {
+			exitOnError(fmt.Errorf("the entries do not match"))
+		}
+	}
+	if required && secret == "" {
+		exitOnError(fmt.Errorf("--%s, --%s-stdin, --%s-file or --generate-password option is required", name, name, name))
+	}
+	return secret, false
+}
+
+// Reports whether the file is a terminal whose echo can be turned off with stty.
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	cmd := exec.Command("stty", "-g")
+	cmd.Stdin = file
+	return cmd.Run() == nil
+}
+
+// Reads a line from the terminal with echo turned off.
+func promptSecret(prompt string) string {
+	fmt.Fprint(os.Stderr, prompt)
+	stty := func(arg string) error {
+		cmd := exec.Command("stty", arg)
+		cmd.Stdin = os.Stdin
+		return cmd.Run()
+	}
+	if err := stty("-echo"); err != nil {
+		exitOnError(fmt.Errorf("failed to turn off terminal echo: %v", err))
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	stty("echo")
+	fmt.Fprintln(os.Stderr)
+	if err != nil && line == "" {
+		exitOnError(err)
+	}
+	return strings.TrimRight(line, "\r\n")
+}
+
+func randomChar(chars string) byte {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+	exitOnError(err)
+	return chars[n.Int64()]
+}
+
+// Generates a random password containing uppercase and lowercase letters, digits and symbols,
+// as required by the API for server, user and shared storage passwords.
+func generatePassword(length int) string {
+	classes := []string{passwordUpper, passwordLower, passwordDigits, passwordSymbols}
+	all := strings.Join(classes, "")
+	password := make([]byte, length)
+	for i := range password {
+		if i < len(classes) {
+			password[i] = randomChar(classes[i])
+		} else {
+			password[i] = randomChar(all)
+		}
+	}
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		exitOnError(err)
+		password[i], password[j.Int64()] = password[j.Int64()], password[i]
+	}
+	return string(password)
+}
+
+// Writes the secret to the file given by --secrets-file, or to a file named after the secret and
+// the ID of its resource in the configuration directory, readable only by the current user.
+// Resources without an ID, like the shared storage access, replace their previous secret.
+func saveSecret(ctx *cli.Context, name, id, secret string) {
+	fileName := ctx.String("secrets-file")
+	if fileName == "" {
+		dir := filepath.Join(getConfigDir(), "secrets")
+		exitOnError(os.MkdirAll(dir, 0700))
+		baseName := name
+		if id != "" {
+			baseName += " " + id
+		}
+		fileName = filepath.Join(dir, unsafeNameChars.ReplaceAllString(baseName, "-"))
+	}
+	file, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	exitOnError(err)
+	defer file.Close()
+	// The file may have existed with wider permissions.
+	exitOnError(file.Chmod(0600))
+	_, err = fmt.Fprintln(file, secret)
+	exitOnError(err)
+	// Standard output is kept for the resource, e.g. with --json.
+	fmt.Fprintf(os.Stderr, "Password of %s written to %s\n", name, fileName)
+}
+
+// Removes the initial password from the server before it is printed. It is stored with saveSecret
+// unless the password was given by the user.
+func takeServerPassword(ctx *cli.Context, server *oneandone.Server, password string, generated bool) {
+	if server.FirstPassword != "" && password == "" {
+		password, generated = server.FirstPassword, true
+	}
+	server.FirstPassword = ""
+	if generated {
+		saveSecret(ctx, "server "+server.Name, server.Id, password)
+	}
+}
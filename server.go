@@ -3,7 +3,6 @@ package main
 import (
 	"fmt"
 	"io/ioutil"
-	"os"
 	"strconv"
 
 	"github.com/1and1/oneandone-cloudserver-sdk-go"
@@ -37,9 +36,9 @@ func init() {
 		Name:  "password, p",
 		Usage: "Password of the server.",
 	}
-	sshKeyPathFlag := cli.StringFlag{
+	sshKeyPathFlag := cli.StringSliceFlag{
 		Name:  "sshkeypath",
-		Usage: "Path to SSH public key file.",
+		Usage: "Path to SSH public key file. Can be repeated.",
 	}
 	cpuFlag := cli.StringFlag{
 		Name:  "cpu",
@@ -73,26 +72,28 @@ func init() {
 
 	bmHwFlags := []cli.Flag{bmFlag}
 
-	tcsFlags := []cli.Flag{
-		cli.StringFlag{
-			Name:  "name, n",
-			Usage: "Name of the server.",
-		},
+	// Settings of a new server that can be stored in a server template.
+	templateFlags := []cli.Flag{
 		cli.StringFlag{
 			Name:  "desc, d",
 			Usage: "Description of the server.",
 		},
-		passwordFlag,
-		sshKeyPathFlag,
+		cli.StringSliceFlag{
+			Name:  "sshkeyid",
+			Usage: "ID or name of an SSH key of the account to install on the server. Can be repeated.",
+		},
+		cli.BoolTFlag{
+			Name:  "sshpassword",
+			Usage: "Allow SSH login with password. Use --sshpassword=false to allow SSH keys only.",
+		},
 		cli.BoolTFlag{
 			Name:  "poweron",
 			Usage: "Power on the server after creating.",
 		},
 		cli.StringFlag{
 			Name:  "osid, a",
-			Usage: "Server appliance ID.",
+			Usage: "Server appliance ID, or os[:version|latest[:arch]] for the newest matching image, e.g. ubuntu:latest or debian:12:64.",
 		},
-		ipIdFlag,
 		datacenterIDFlag,
 		fpIdFlag,
 		lbIdFlag,
@@ -100,7 +101,34 @@ func init() {
 			Name:  "monitorpolicyid, m",
 			Usage: "Monitoring policy ID to use with the server.",
 		},
+		cli.StringFlag{
+			Name:  "pnetid",
+			Usage: "ID of the private network to add the server to.",
+		},
+		cli.StringFlag{
+			Name:  "ipv6range",
+			Usage: "IPv6 range to assign to the server, e.g. 64.",
+		},
+	}
+
+	tcsFlags := []cli.Flag{
+		cli.StringFlag{
+			Name:  "name, n",
+			Usage: "Name of the server.",
+		},
+		cli.StringFlag{
+			Name:  "template, t",
+			Usage: "Name of the server template to create the server from. Options given override the template.",
+		},
+		cli.StringFlag{
+			Name:  "hostname",
+			Usage: "Host name of the server.",
+		},
+		sshKeyPathFlag,
+		ipIdFlag,
 	}
+	tcsFlags = append(tcsFlags, templateFlags...)
+	tcsFlags = append(tcsFlags, secretFlags(passwordFlag)...)
 
 	serverOps = []cli.Command{
 		{
@@ -118,7 +146,7 @@ func init() {
 					Name:   "createbaremetal",
 					Usage:  "Creates new baremetal server.",
 					Flags:  append(bmHwFlags, tcsFlags...),
-					Action: createbaremetalServer,
+					Action: createServer,
 				},
 				{
 					Name:  "clone",
@@ -161,6 +189,41 @@ func init() {
 					Usage:  "Lists available fixed-size flavors.",
 					Action: listServerFlavors,
 				},
+				{
+					Name:  "flavors",
+					Usage: "Lists fixed-size flavors or baremetal models with the minimum hardware, the cheapest first.",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "min-cores",
+							Usage: "Minimum number of cores.",
+						},
+						cli.StringFlag{
+							Name:  "min-ram",
+							Usage: "Minimum RAM size in GB.",
+						},
+						cli.StringFlag{
+							Name:  "min-disk",
+							Usage: "Minimum total disk size in GB.",
+						},
+						cli.BoolFlag{
+							Name:  "baremetal",
+							Usage: "List baremetal models instead of fixed-size flavors.",
+						},
+						cli.StringFlag{
+							Name:  "datacenterid",
+							Usage: "Data center ID or country code added to the command printed with --command. The flavors are not filtered by data center.",
+						},
+						cli.StringFlag{
+							Name:  "osid",
+							Usage: "Server appliance ID to use in the 'server create' command.",
+						},
+						cli.BoolFlag{
+							Name:  "command",
+							Usage: "Print the 'server create' command for the cheapest flavor instead of the list.",
+						},
+					},
+					Action: findServerFlavors,
+				},
 				{
 					Name:   "baremetalmodels",
 					Usage:  "Lists available baremetal models.",
@@ -225,6 +288,58 @@ func init() {
 					},
 					Action: deleteServer,
 				},
+				{
+					Name:  "template",
+					Usage: "Manages server templates.",
+					Subcommands: []cli.Command{
+						{
+							Name:   "list",
+							Usage:  "Lists server templates.",
+							Action: listServerTemplates,
+						},
+						{
+							Name:  "rm",
+							Usage: "Deletes server template.",
+							Flags: []cli.Flag{
+								cli.StringFlag{
+									Name:  "name, n",
+									Usage: "Name of the server template.",
+								},
+							},
+							Action: deleteServerTemplate,
+						},
+						{
+							Name:  "save",
+							Usage: "Saves server template from options or from an existing server.",
+							Flags: append(append([]cli.Flag{
+								cli.StringFlag{
+									Name:  "name, n",
+									Usage: "Name of the server template.",
+								},
+								cli.StringFlag{
+									Name:  "id, i",
+									Usage: "ID of the server to take the settings from. Options given override them.",
+								},
+								cli.StringFlag{
+									Name:  "modelid",
+									Usage: "Baremetal model ID desired for the server.",
+								},
+							}, hwFlags...), templateFlags...),
+							Action: saveServerTemplate,
+						},
+						{
+							Name:  "show",
+							Usage: "Shows server template.",
+							Flags: []cli.Flag{
+								cli.StringFlag{
+									Name:  "name, n",
+									Usage: "Name of the server template.",
+								},
+							},
+							Action: showServerTemplate,
+						},
+					},
+				},
 				{
 					Name:  "update",
 					Usage: "Updates server's name and description.",
@@ -332,6 +447,37 @@ func init() {
 					Flags:  append([]cli.Flag{cpuFlag, coresFlag, flavorFlag, ramFlag}, serverIdFlag),
 					Action: modifyServerHardware,
 				},
+				{
+					Name:  "rightsize",
+					Usage: "Recommends smaller or larger hardware from the CPU, RAM and disk usage of servers.",
+					Flags: []cli.Flag{
+						serverIdFlag,
+						cli.BoolFlag{
+							Name:  "all",
+							Usage: "Check all servers instead of the server given by --id.",
+						},
+						cli.StringFlag{
+							Name:  "period",
+							Usage: "Time range of the usage data: LAST_HOUR, LAST_24H, LAST_7D, LAST_30D, LAST_365D or CUSTOM. Default: LAST_30D.",
+						},
+						startDateFlag,
+						endDateFlag,
+						cli.IntFlag{
+							Name:  "target",
+							Value: 70,
+							Usage: "Target 95th percentile utilization in percent.",
+						},
+						cli.BoolFlag{
+							Name:  "apply",
+							Usage: "Change the hardware of the servers after confirmation.",
+						},
+						cli.BoolFlag{
+							Name:  "yes",
+							Usage: "Apply the recommendations without confirmation.",
+						},
+					},
+					Action: rightsizeServers,
+				},
 				{
 					Name:   "imginfo",
 					Usage:  "Shows information about server's image.",
@@ -341,15 +487,14 @@ func init() {
 				{
 					Name:  "imgupdate",
 					Usage: "Reinstalls new image into server.",
-					Flags: []cli.Flag{
+					Flags: append([]cli.Flag{
 						serverIdFlag,
 						cli.StringFlag{
 							Name:  "imgid",
 							Usage: "ID of the image.",
 						},
-						passwordFlag,
 						fpIdFlag,
-					},
+					}, secretFlags(passwordFlag)...),
 					Action: reInsServerImage,
 				},
 				{
@@ -491,104 +636,101 @@ func init() {
 }
 
 // Helper method
-func getHardwareConfig(ctx *cli.Context) oneandone.Hardware {
-	var hardware oneandone.Hardware
+// Creates a virtual or, with the createbaremetal command, a baremetal server.
+func createServer(ctx *cli.Context) {
+	template := &serverTemplate{}
+	if ctx.String("template") != "" {
+		template = loadServerTemplate(ctx.String("template"))
+	}
+	template.applyFlags(ctx)
 
-	fixedSizeID := ctx.String("fixsizeid")
-	if fixedSizeID != "" {
-		hardware = oneandone.Hardware{
-			FixedInsSizeId: fixedSizeID,
-		}
-	} else {
-		hardware = oneandone.Hardware{
-			FixedInsSizeId:    ctx.String("fixsizeid"),
-			Vcores:            stringFlag2Int(ctx, "cpu"),
-			CoresPerProcessor: stringFlag2Int(ctx, "cores"),
-			Ram:               stringFlag2Float32(ctx, "ram"),
-			Hdds: []oneandone.Hdd{
-				oneandone.Hdd{
-					Size:   stringFlag2Int(ctx, "hdsize"),
-					IsMain: true,
-				},
-			},
-		}
+	name := getRequiredOption(ctx, "name")
+	if template.ApplianceId == "" {
+		getRequiredOption(ctx, "osid")
+	}
+	if ctx.Command.Name == "createbaremetal" && template.BaremetalModelId == "" {
+		getRequiredOption(ctx, "modelid")
 	}
+	serverType := "cloud"
+	if template.BaremetalModelId != "" {
+		serverType = "baremetal"
+	}
+	template.ApplianceId = resolveApplianceId(template.ApplianceId, serverType)
+	req := template.request()
+	req.Name = name
+	req.Hostname = ctx.String("hostname")
+	req.IpId = ctx.String("ipid")
+	validateServerRequest(req, template.settingName(ctx))
+	req.SSHKey, req.PublicKey = getServerSSHKeys(ctx, template.SSHKeys)
 
-	return hardware
+	password, generated := getSecret(ctx, "password", false)
+	req.Password = password
+	_, server, err := api.CreateServer(req)
+	exitOnError(err)
+	takeServerPassword(ctx, server, password, generated)
+	output(ctx, server, okWaitMessage, false, nil, nil)
 }
 
-func createServer(ctx *cli.Context) {
-	sshKey := ""
-	sshKeyPath := ctx.String("sshkeypath")
-	if sshKeyPath != "" {
-		_, err := os.Stat(sshKeyPath)
-		if err != nil {
-			exitOnError(fmt.Errorf("The file specified by `--sshkeypath` does not exist."))
-		} else {
-			publicKey, err := ioutil.ReadFile(sshKeyPath)
-			if err != nil {
-				exitOnError(fmt.Errorf("Failed to read SSH key. Error: %s", err.Error()))
+// Returns the public key to install on a new server and the IDs of the account's SSH keys to install.
+// Key files matching a key of the account are installed by its ID, the API accepts only one other key.
+func getServerSSHKeys(ctx *cli.Context, names []string) (string, []string) {
+	files := ctx.StringSlice("sshkeypath")
+	if len(names) == 0 && len(files) == 0 {
+		return "", nil
+	}
+	sshKeys, err := api.ListSSHKeys()
+	exitOnError(err)
+
+	var ids []string
+	addId := func(id string) {
+		for _, existing := range ids {
+			if existing == id {
+				return
 			}
-			sshKey = string(publicKey)
+		}
+		ids = append(ids, id)
+	}
+	for _, name := range names {
+		found := false
+		for _, key := range sshKeys {
+			if key.Id == name || key.Name == name {
+				addId(key.Id)
+				found = true
+				break
+			}
+		}
+		if !found {
+			exitOnError(fmt.Errorf("SSH key '%s' not found", name))
 		}
 	}
-	req := oneandone.ServerRequest{
-		Name:               getRequiredOption(ctx, "name"),
-		Description:        ctx.String("desc"),
-		ApplianceId:        getRequiredOption(ctx, "osid"),
-		Password:           ctx.String("password"),
-		SSHKey:             sshKey,
-		PowerOn:            ctx.Bool("poweron"),
-		FirewallPolicyId:   ctx.String("firewallid"),
-		IpId:               ctx.String("ipid"),
-		LoadBalancerId:     ctx.String("loadbalancerid"),
-		MonitoringPolicyId: ctx.String("monitorpolicyid"),
-		DatacenterId:       ctx.String("datacenterid"),
-		Hardware:           getHardwareConfig(ctx),
-	}
-	_, server, err := api.CreateServer(&req)
-	exitOnError(err)
-	output(ctx, server, okWaitMessage, false, nil, nil)
-}
 
-func createbaremetalServer(ctx *cli.Context) {
-	sshKey := ""
-	sshKeyPath := ctx.String("sshkeypath")
-	modelId := ctx.String("modelid")
-	if sshKeyPath != "" {
-		_, err := os.Stat(sshKeyPath)
+	publicKey := ""
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			exitOnError(fmt.Errorf("Failed to read SSH key. Error: %s", err.Error()))
+		}
+		key, err := parseSshPublicKey(string(content))
 		if err != nil {
-			exitOnError(fmt.Errorf("The file specified by `--sshkeypath` does not exist."))
-		} else {
-			publicKey, err := ioutil.ReadFile(sshKeyPath)
-			if err != nil {
-				exitOnError(fmt.Errorf("Failed to read SSH key. Error: %s", err.Error()))
+			exitOnError(fmt.Errorf("%s: %v", file, err))
+		}
+		found := false
+		for _, accountKey := range sshKeys {
+			if normalizeFingerprint(accountKey.Md5) == normalizeFingerprint(key.Md5) {
+				addId(accountKey.Id)
+				found = true
+				break
 			}
-			sshKey = string(publicKey)
 		}
+		if found {
+			continue
+		}
+		if publicKey != "" {
+			exitOnError(fmt.Errorf("only one key file not in the account can be used, import the others with 'sshkey import --file %s'", file))
+		}
+		publicKey = key.String()
 	}
-	hardware := oneandone.Hardware{
-		BaremetalModelId: modelId,
-	}
-
-	req := oneandone.ServerRequest{
-		Name:               getRequiredOption(ctx, "name"),
-		Description:        ctx.String("desc"),
-		ApplianceId:        getRequiredOption(ctx, "osid"),
-		Password:           ctx.String("password"),
-		SSHKey:             sshKey,
-		PowerOn:            ctx.Bool("poweron"),
-		FirewallPolicyId:   ctx.String("firewallid"),
-		IpId:               ctx.String("ipid"),
-		LoadBalancerId:     ctx.String("loadbalancerid"),
-		MonitoringPolicyId: ctx.String("monitorpolicyid"),
-		DatacenterId:       ctx.String("datacenterid"),
-		Hardware:           hardware,
-		ServerType:         "baremetal",
-	}
-	_, server, err := api.CreateServer(&req)
-	exitOnError(err)
-	output(ctx, server, okWaitMessage, false, nil, nil)
+	return publicKey, ids
 }
 
 func cloneServer(ctx *cli.Context) {
@@ -781,7 +923,10 @@ func modifyServerHardware(ctx *cli.Context) {
 		CoresPerProcessor: cores,
 		Ram:               ram,
 	}
-	server, err := api.UpdateServerHardware(id, &hardware)
+	server, err := api.GetServer(id)
+	exitOnError(err)
+	validateHardwareUpdate(server, &hardware)
+	server, err = api.UpdateServerHardware(id, &hardware)
 	exitOnError(err)
 	output(ctx, server, okWaitMessage, false, nil, nil)
 }
@@ -810,9 +955,12 @@ func addServerHdds(ctx *cli.Context) {
 	id := getRequiredOption(ctx, "id")
 	sizes := getIntSliceOption(ctx, "size", true)
 	hdds := new(oneandone.ServerHdds)
+	var errs []string
 	for _, s := range sizes {
+		errs = append(errs, validateHddSize("--size", s)...)
 		hdds.Hdds = append(hdds.Hdds, oneandone.Hdd{Size: s})
 	}
+	exitOnValidationErrors(errs)
 	server, err := api.AddServerHdds(id, hdds)
 	exitOnError(err)
 	output(ctx, server, okWaitMessage, false, nil, nil)
@@ -842,9 +990,7 @@ func resizeServerHdd(ctx *cli.Context) {
 			break
 		}
 	}
-	if newSize < 20 || newSize > 2000 || newSize%10 != 0 {
-		exitOnError(fmt.Errorf("Invalid value for hard disk size. The size must be at least 20, at most 2000 and multiple of 0.5."))
-	}
+	exitOnValidationErrors(validateHddSize("--newsize", newSize))
 
 	server, err = api.ResizeServerHdd(serverId, hddId, newSize)
 	exitOnError(err)
@@ -861,10 +1007,11 @@ func showServerImage(ctx *cli.Context) {
 func reInsServerImage(ctx *cli.Context) {
 	serverId := getRequiredOption(ctx, "id")
 	imageId := getRequiredOption(ctx, "imgid")
-	pass := ctx.String("password")
+	pass, generated := getSecret(ctx, "password", false)
 	fpId := ctx.String("firewallid")
 	server, err := api.ReinstallServerImage(serverId, imageId, pass, fpId)
 	exitOnError(err)
+	takeServerPassword(ctx, server, pass, generated)
 	output(ctx, server, okWaitMessage, false, nil, nil)
 }
 
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/1and1/oneandone-cloudserver-sdk-go"
+	"github.com/codegangsta/cli"
+)
+
+// Average number of hours in a month used to convert hourly prices.
+const hoursPerMonth = 730
+
+// Fixed-size flavor or baremetal model with its total hardware and monthly price.
+type serverFlavor struct {
+	Id        string   `json:"id"`
+	Name      string   `json:"name"`
+	Baremetal bool     `json:"baremetal"`
+	Cores     int      `json:"cores"`
+	Ram       float32  `json:"ram"`
+	Disk      int      `json:"disk"`
+	Gross     *float64 `json:"monthly_price_gross,omitempty"`
+	Net       *float64 `json:"monthly_price_net,omitempty"`
+}
+
+// Converts a price to a monthly price according to its unit. ok is false for unknown units.
+func monthlyPrice(price float64, unit string) (monthly float64, ok bool) {
+	unit = strings.ToLower(unit)
+	switch {
+	case strings.Contains(unit, "hour"):
+		return price * hoursPerMonth, true
+	case strings.Contains(unit, "month"), unit == "":
+		return price, true
+	}
+	return 0, false
+}
+
+func getServerFlavors(baremetal bool, pricing *oneandone.Pricing) []serverFlavor {
+	var flavors []serverFlavor
+	if baremetal {
+		models, err := api.ListBaremetalModels()
+		exitOnError(err)
+		for _, m := range models {
+			f := serverFlavor{Id: m.Id, Name: m.Name, Baremetal: true}
+			if hw := m.Hardware; hw != nil {
+				f.Cores, f.Ram = hw.Cores, hw.Ram
+				for _, hdd := range hw.Hdds {
+					f.Disk += hdd.Size
+				}
+			}
+			flavors = append(flavors, f)
+		}
+	} else {
+		sizes, err := api.ListFixedInstanceSizes()
+		exitOnError(err)
+		for _, s := range sizes {
+			f := serverFlavor{Id: s.Id, Name: s.Name}
+			if hw := s.Hardware; hw != nil {
+				f.Cores, f.Ram = hw.Vcores, hw.Ram
+				for _, hdd := range hw.Hdds {
+					f.Disk += hdd.Size
+				}
+			}
+			flavors = append(flavors, f)
+		}
+	}
+
+	if pricing.Plan == nil || pricing.Plan.Servers == nil {
+		return flavors
+	}
+	for i := range flavors {
+		for _, p := range pricing.Plan.Servers.FixedServers {
+			if !strings.EqualFold(p.Name, flavors[i].Name) {
+				continue
+			}
+			gross, ok := monthlyPrice(p.GrossPrice, p.Unit)
+			net, _ := monthlyPrice(p.NetPrice, p.Unit)
+			if ok {
+				flavors[i].Gross, flavors[i].Net = &gross, &net
+			}
+			break
+		}
+	}
+	return flavors
+}
+
+func formatPrice(price *float64) string {
+	if price == nil {
+		return "-"
+	}
+	return strconv.FormatFloat(*price, 'f', 2, 64)
+}
+
+// Returns the ID of the data center given by its ID or country code.
+func getDatacenterId(idOrCode string) string {
+	datacenters, err := api.ListDatacenters()
+	exitOnError(err)
+	var codes []string
+	for _, dc := range datacenters {
+		if dc.Id == idOrCode || strings.EqualFold(dc.CountryCode, idOrCode) {
+			return dc.Id
+		}
+		codes = append(codes, dc.CountryCode)
+	}
+	exitOnError(fmt.Errorf("data center '%s' not found, valid country codes are %s", idOrCode, strings.Join(codes, ", ")))
+	return ""
+}
+
+// Returns the 'server create' command for the flavor.
+func serverCreateCommand(f serverFlavor, osId, datacenterId string) string {
+	if osId == "" {
+		osId = "<appliance-id>"
+	}
+	cmd := "oneandone server create --name <name> --fixsizeid " + f.Id
+	if f.Baremetal {
+		cmd = "oneandone server createbaremetal --name <name> --modelid " + f.Id
+	}
+	cmd += " --osid " + osId
+	if datacenterId != "" {
+		cmd += " --datacenterid " + datacenterId
+	}
+	return cmd
+}
+
+// Lists the flavors meeting the minimum hardware, the cheapest first. Flavors without a price are listed last.
+func findServerFlavors(ctx *cli.Context) {
+	minCores := stringFlag2Int(ctx, "min-cores")
+	minRam := stringFlag2Float32(ctx, "min-ram")
+	minDisk := stringFlag2Int(ctx, "min-disk")
+	// The catalogs don't tell in which data centers a flavor is offered, so the data center
+	// is only passed on to the printed command.
+	var datacenterId string
+	if ctx.String("datacenterid") != "" {
+		if !ctx.Bool("command") {
+			exitOnError(fmt.Errorf("--datacenterid is only used with --command, flavors cannot be filtered by data center"))
+		}
+		datacenterId = getDatacenterId(ctx.String("datacenterid"))
+	}
+
+	pricing := getPricing()
+	var flavors []serverFlavor
+	for _, f := range getServerFlavors(ctx.Bool("baremetal"), pricing) {
+		if f.Cores >= minCores && f.Ram >= minRam && f.Disk >= minDisk {
+			flavors = append(flavors, f)
+		}
+	}
+	sort.SliceStable(flavors, func(i, j int) bool {
+		a, b := flavors[i], flavors[j]
+		if (a.Net == nil) != (b.Net == nil) {
+			return a.Net != nil
+		}
+		if a.Net != nil && *a.Net != *b.Net {
+			return *a.Net < *b.Net
+		}
+		return a.Name < b.Name
+	})
+	if len(flavors) == 0 {
+		exitOnError(fmt.Errorf("no flavor has at least %d cores, %s GB RAM and %d GB disk",
+			minCores, strconv.FormatFloat(float64(minRam), 'f', -1, 32), minDisk))
+	}
+
+	if ctx.Bool("command") {
+		fmt.Println(serverCreateCommand(flavors[0], ctx.String("osid"), datacenterId))
+		return
+	}
+	data := make([][]string, len(flavors))
+	for i, f := range flavors {
+		data[i] = []string{
+			f.Id, f.Name,
+			strconv.Itoa(f.Cores),
+			strconv.FormatFloat(float64(f.Ram), 'f', -1, 32),
+			strconv.Itoa(f.Disk),
+			formatPrice(f.Gross),
+			formatPrice(f.Net),
+		}
+	}
+	header := []string{"ID", "Name", "Cores", "RAM (GB)", "Disk Size (GB)",
+		fmt.Sprintf("Monthly Gross (%s)", pricing.Currency), fmt.Sprintf("Monthly Net (%s)", pricing.Currency)}
+	output(ctx, flavors, "", false, &header, &data)
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/1and1/oneandone-cloudserver-sdk-go"
+	"github.com/codegangsta/cli"
+)
+
+// Utilization of a resource in percent over the monitoring period.
+type utilization struct {
+	P95  float64 `json:"p95"`
+	Peak float64 `json:"peak"`
+}
+
+type rightsizeRecommendation struct {
+	ServerId         string              `json:"server_id"`
+	ServerName       string              `json:"server_name"`
+	Current          string              `json:"current"`
+	CurrentPrice     *float64            `json:"current_monthly_price,omitempty"`
+	Cpu              *utilization        `json:"cpu,omitempty"`
+	Ram              *utilization        `json:"ram,omitempty"`
+	Disk             *utilization        `json:"disk,omitempty"`
+	Action           string              `json:"action"`
+	Recommended      string              `json:"recommended,omitempty"`
+	RecommendedPrice *float64            `json:"recommended_monthly_price,omitempty"`
+	Saving           *float64            `json:"monthly_saving,omitempty"`
+	Hardware         *oneandone.Hardware `json:"hardware,omitempty"`
+	Note             string              `json:"note,omitempty"`
+}
+
+// Returns the 95th percentile and the peak of the usage data, or nil if there is no data.
+func getUtilization(data []float64) *utilization {
+	if len(data) == 0 {
+		return nil
+	}
+	sorted := append([]float64(nil), data...)
+	sort.Float64s(sorted)
+	rank := int(math.Ceil(0.95*float64(len(sorted)))) - 1
+	return &utilization{P95: sorted[rank], Peak: sorted[len(sorted)-1]}
+}
+
+// Returns the size needed to keep the 95th percentile at the target utilization and the peak below 100%.
+func requiredSize(current float64, u *utilization, target int) float64 {
+	if u == nil {
+		return current
+	}
+	return math.Max(current*u.P95/float64(target), current*u.Peak/100)
+}
+
+// Names of the flexible server pricing items charged per core, per GB of RAM and per GB of disk.
+const (
+	flexCorePriceItem = "CPU"
+	flexRamPriceItem  = "RAM"
+	flexDiskPriceItem = "HDD"
+)
+
+// Returns the monthly price of a flexible configuration from the per unit prices, or nil if a price is missing.
+func flexMonthlyPrice(pricing *oneandone.Pricing, cores int, ram float32, disk int) *float64 {
+	if pricing.Plan == nil || pricing.Plan.Servers == nil {
+		return nil
+	}
+	prices := make(map[string]float64)
+	for _, p := range pricing.Plan.Servers.FlexServers {
+		if price, ok := monthlyPrice(p.NetPrice, p.Unit); ok {
+			prices[strings.ToUpper(p.Name)] = price
+		}
+	}
+	corePrice, coreFound := prices[flexCorePriceItem]
+	ramPrice, ramFound := prices[flexRamPriceItem]
+	diskPrice, diskFound := prices[flexDiskPriceItem]
+	if !coreFound || !ramFound || !diskFound {
+		return nil
+	}
+	total := corePrice*float64(cores) + ramPrice*float64(ram) + diskPrice*float64(disk)
+	return &total
+}
+
+func flexSummary(cores int, ram float32, disk int) string {
+	return fmt.Sprintf("flex %d cores, %s GB RAM, %d GB disk",
+		cores, strconv.FormatFloat(float64(ram), 'f', -1, 32), disk)
+}
+
+// Compares the utilization of the server with its hardware and recommends the cheapest fixed size or
+// flexible configuration that keeps the 95th percentile of CPU, RAM and disk usage at the target.
+func rightsizeServer(server *oneandone.Server, usage *oneandone.MonServerUsageDetails,
+	flavors []serverFlavor, pricing *oneandone.Pricing, target int) *rightsizeRecommendation {
+	rec := &rightsizeRecommendation{ServerId: server.Id, ServerName: server.Name}
+	hw := server.Hardware
+	if server.ServerType == "baremetal" || hw == nil {
+		rec.Action = "skip"
+		rec.Note = "only cloud servers can be resized"
+		return rec
+	}
+
+	disk := 0
+	for _, hdd := range hw.Hdds {
+		disk += hdd.Size
+	}
+	var current *serverFlavor
+	for i, f := range flavors {
+		if hw.FixedInsSizeId != "" && f.Id == hw.FixedInsSizeId {
+			current = &flavors[i]
+		}
+	}
+	if current != nil {
+		rec.Current, rec.CurrentPrice = "fixed size "+current.Name, current.Net
+	} else {
+		rec.Current = flexSummary(hw.Vcores, hw.Ram, disk)
+		rec.CurrentPrice = flexMonthlyPrice(pricing, hw.Vcores, hw.Ram, disk)
+	}
+
+	var cpuData, ramData, diskData []float64
+	if usage.CpuStatus != nil {
+		for _, d := range usage.CpuStatus.Data {
+			cpuData = append(cpuData, float64(d.UsedPercent))
+		}
+	}
+	if usage.RamStatus != nil {
+		for _, d := range usage.RamStatus.Data {
+			ramData = append(ramData, float64(d.UsedPercent))
+		}
+	}
+	if usage.DiskStatus != nil {
+		for _, d := range usage.DiskStatus.Data {
+			diskData = append(diskData, float64(d.UsedPercent))
+		}
+	}
+	rec.Cpu, rec.Ram, rec.Disk = getUtilization(cpuData), getUtilization(ramData), getUtilization(diskData)
+	if rec.Cpu == nil && rec.Ram == nil {
+		rec.Action = "skip"
+		rec.Note = "no CPU and RAM usage data in the period"
+		return rec
+	}
+
+	needCores := int(math.Max(1, math.Ceil(requiredSize(float64(hw.Vcores), rec.Cpu, target))))
+	needRam := float32(math.Max(1, math.Ceil(requiredSize(float64(hw.Ram), rec.Ram, target))))
+	// Disks can only grow.
+	needDisk := int(math.Max(float64(disk), math.Ceil(requiredSize(float64(disk), rec.Disk, target))))
+
+	flexPrice := flexMonthlyPrice(pricing, needCores, needRam, disk)
+	rec.Recommended, rec.RecommendedPrice = flexSummary(needCores, needRam, disk), flexPrice
+	cpp := hw.CoresPerProcessor
+	if cpp <= 0 || needCores%cpp != 0 {
+		cpp = 1
+	}
+	rec.Hardware = &oneandone.Hardware{Vcores: needCores, CoresPerProcessor: cpp, Ram: needRam}
+	cores, ram := needCores, needRam
+	for i, f := range flavors {
+		if f.Baremetal || f.Net == nil || f.Cores < needCores || f.Ram < needRam || f.Disk < needDisk {
+			continue
+		}
+		if rec.RecommendedPrice == nil || *f.Net < *rec.RecommendedPrice {
+			rec.Recommended, rec.RecommendedPrice = "fixed size "+f.Name, flavors[i].Net
+			rec.Hardware = &oneandone.Hardware{FixedInsSizeId: f.Id}
+			cores, ram = f.Cores, f.Ram
+		}
+	}
+	if rec.Hardware.FixedInsSizeId == "" && needDisk > disk {
+		rec.Note = fmt.Sprintf("disk usage requires %d GB, resize the disk with 'server hddupdate'", needDisk)
+	}
+
+	switch {
+	case current != nil && rec.Hardware.FixedInsSizeId == current.Id,
+		current == nil && rec.Hardware.FixedInsSizeId == "" && cores == hw.Vcores && ram == hw.Ram:
+		rec.Action = "keep"
+		rec.Recommended, rec.RecommendedPrice, rec.Hardware = "", nil, nil
+		return rec
+	case cores <= hw.Vcores && ram <= hw.Ram:
+		rec.Action = "downsize"
+	case cores >= hw.Vcores && ram >= hw.Ram:
+		rec.Action = "upsize"
+	default:
+		rec.Action = "resize"
+	}
+	if rec.CurrentPrice != nil && rec.RecommendedPrice != nil {
+		saving := *rec.CurrentPrice - *rec.RecommendedPrice
+		rec.Saving = &saving
+	}
+	return rec
+}
+
+func formatUtilization(u *utilization) string {
+	if u == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f / %.0f", u.P95, u.Peak)
+}
+
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// Asks the user to confirm with 'y' on the terminal. Without a terminal, the answer is no.
+func confirm(prompt string) bool {
+	if !isTerminal(os.Stdin) {
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", prompt)
+	answer, _ := stdinReader.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func rightsizeServers(ctx *cli.Context) {
+	var servers []oneandone.Server
+	if ctx.Bool("all") {
+		list, err := api.ListServers()
+		exitOnError(err)
+		for _, s := range list {
+			server, err := api.GetServer(s.Id)
+			exitOnError(err)
+			servers = append(servers, *server)
+		}
+	} else {
+		server, err := api.GetServer(getRequiredOption(ctx, "id"))
+		exitOnError(err)
+		servers = append(servers, *server)
+	}
+
+	period := "LAST_30D"
+	if ctx.String("period") != "" {
+		period = validatePeriod(strings.ToUpper(ctx.String("period")))
+	}
+	target := validateIntRange("target", ctx.Int("target"), 10, 100)
+	if ctx.Bool("apply") && !ctx.Bool("yes") && !isTerminal(os.Stdin) {
+		exitOnError(fmt.Errorf("--apply asks for confirmation on the terminal, use --yes to apply without confirmation"))
+	}
+
+	pricing := getPricing()
+	flavors := getServerFlavors(false, pricing)
+	var recs []*rightsizeRecommendation
+	for i := range servers {
+		var usage *oneandone.MonServerUsageDetails
+		var err error
+		if period == "CUSTOM" {
+			usage, err = api.GetMonitoringServerUsage(servers[i].Id, period,
+				getDateOption(ctx, "startdate", true), getDateOption(ctx, "enddate", true))
+		} else {
+			usage, err = api.GetMonitoringServerUsage(servers[i].Id, period)
+		}
+		exitOnError(err)
+		recs = append(recs, rightsizeServer(&servers[i], usage, flavors, pricing, target))
+	}
+
+	data := make([][]string, len(recs))
+	for i, r := range recs {
+		data[i] = []string{
+			r.ServerName,
+			r.Current,
+			formatUtilization(r.Cpu),
+			formatUtilization(r.Ram),
+			formatUtilization(r.Disk),
+			r.Action,
+			r.Recommended,
+			formatPrice(r.Saving),
+			r.Note,
+		}
+	}
+	header := []string{"Server", "Current", "CPU % (p95 / Peak)", "RAM % (p95 / Peak)", "Disk % (p95 / Peak)",
+		"Action", "Recommended", fmt.Sprintf("Monthly Saving (%s)", pricing.Currency), "Note"}
+	output(ctx, recs, "", false, &header, &data)
+
+	if !ctx.Bool("apply") {
+		return
+	}
+	for _, r := range recs {
+		if r.Hardware == nil {
+			continue
+		}
+		if !ctx.Bool("yes") && !confirm(fmt.Sprintf("Change server '%s' from %s to %s?", r.ServerName, r.Current, r.Recommended)) {
+			continue
+		}
+		_, err := api.UpdateServerHardware(r.ServerId, r.Hardware)
+		exitOnError(err)
+		fmt.Fprintf(os.Stderr, "Hardware of server '%s' is being changed to %s.\n", r.ServerName, r.Recommended)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/1and1/oneandone-cloudserver-sdk-go"
+)
+
+func TestGetUtilization(t *testing.T) {
+	tests := []struct {
+		data     []float64
+		expected *utilization
+	}{
+		{nil, nil},
+		{[]float64{42}, &utilization{42, 42}},
+		{[]float64{50, 10, 30, 20, 40}, &utilization{50, 50}},
+		// The 95th percentile of 20 values is the 19th value.
+		{[]float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 100}, &utilization{19, 100}},
+	}
+	for _, test := range tests {
+		if u := getUtilization(test.data); !reflect.DeepEqual(test.expected, u) {
+			t.Errorf("%v: expected %+v, got %+v", test.data, test.expected, u)
+		}
+	}
+}
+
+func TestRequiredSize(t *testing.T) {
+	tests := []struct {
+		current  float64
+		u        *utilization
+		target   int
+		expected float64
+	}{
+		{4, nil, 70, 4},
+		{4, &utilization{P95: 35, Peak: 50}, 70, 2},
+		// The peak must stay below 100%.
+		{4, &utilization{P95: 35, Peak: 100}, 70, 4},
+		{2, &utilization{P95: 105, Peak: 100}, 70, 3},
+	}
+	for _, test := range tests {
+		if size := requiredSize(test.current, test.u, test.target); math.Abs(size-test.expected) > 1e-9 {
+			t.Errorf("%v %+v %d: expected %v, got %v", test.current, test.u, test.target, test.expected, size)
+		}
+	}
+}
+
+func newTestUsage(t *testing.T, cpu, ram []float64) *oneandone.MonServerUsageDetails {
+	status := func(values []float64) string {
+		var data []string
+		for _, v := range values {
+			data = append(data, fmt.Sprintf(`{"used_percent": %v}`, v))
+		}
+		return `{"data": [` + strings.Join(data, ",") + `]}`
+	}
+	usage := &oneandone.MonServerUsageDetails{}
+	content := fmt.Sprintf(`{"cpu": %s, "ram": %s}`, status(cpu), status(ram))
+	if err := json.Unmarshal([]byte(content), usage); err != nil {
+		t.Fatal(err.Error())
+	}
+	return usage
+}
+
+func newTestServer(serverType string, cores int, ram float32, disk int) *oneandone.Server {
+	server := &oneandone.Server{Name: "web", ServerType: serverType}
+	server.Id = "S1"
+	server.Hardware = &oneandone.Hardware{Vcores: cores, CoresPerProcessor: 1, Ram: ram,
+		Hdds: []oneandone.Hdd{{Size: disk, IsMain: true}}}
+	return server
+}
+
+func TestRightsizeServer(t *testing.T) {
+	pricing := &oneandone.Pricing{}
+	// 1 per core, GB of RAM and 10 GB of disk and month.
+	err := json.Unmarshal([]byte(`{"currency": "EUR", "pricing_plans": {"servers": {"flexible_server": [
+		{"name": "CPU", "price_net": 1, "unit": "month"},
+		{"name": "RAM", "price_net": 1, "unit": "month"},
+		{"name": "HDD", "price_net": 0.1, "unit": "month"}]}}}`), pricing)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	price := func(p float64) *float64 { return &p }
+	fixedL := serverFlavor{Id: "L1", Name: "L", Cores: 2, Ram: 4, Disk: 100, Net: price(3)}
+	fixedS := serverFlavor{Id: "S1", Name: "S", Cores: 1, Ram: 1, Disk: 40, Net: price(1)}
+	low := []float64{10, 15, 20, 20}
+	full := []float64{70, 70, 70, 70}
+
+	tests := []struct {
+		server      *oneandone.Server
+		usage       *oneandone.MonServerUsageDetails
+		flavors     []serverFlavor
+		action      string
+		recommended string
+		hardware    *oneandone.Hardware
+		saving      *float64
+	}{
+		{newTestServer("baremetal", 4, 8, 100), newTestUsage(t, low, low), nil, "skip", "", nil, nil},
+		{newTestServer("cloud", 4, 8, 100), newTestUsage(t, nil, nil), nil, "skip", "", nil, nil},
+		// Flexible 4 cores and 8 GB for 22 a month, 2 cores and 4 GB are enough.
+		{newTestServer("cloud", 4, 8, 100), newTestUsage(t, low, []float64{30, 35, 35}), nil,
+			"downsize", "flex 2 cores, 4 GB RAM, 100 GB disk", &oneandone.Hardware{Vcores: 2, CoresPerProcessor: 1, Ram: 4}, price(6)},
+		// The fixed size is cheaper than the flexible configuration, the small one is too small.
+		{newTestServer("cloud", 4, 8, 100), newTestUsage(t, low, []float64{30, 35, 35}), []serverFlavor{fixedS, fixedL},
+			"downsize", "fixed size L", &oneandone.Hardware{FixedInsSizeId: "L1"}, price(19)},
+		{newTestServer("cloud", 2, 4, 100), newTestUsage(t, full, full), nil, "keep", "", nil, nil},
+		{newTestServer("cloud", 2, 4, 100), newTestUsage(t, []float64{90, 95, 100}, full), nil,
+			"upsize", "flex 3 cores, 4 GB RAM, 100 GB disk", &oneandone.Hardware{Vcores: 3, CoresPerProcessor: 1, Ram: 4}, price(-1)},
+	}
+	for i, test := range tests {
+		rec := rightsizeServer(test.server, test.usage, test.flavors, pricing, 70)
+		if rec.Action != test.action || rec.Recommended != test.recommended {
+			t.Errorf("%d: expected %s to '%s', got %s to '%s'", i, test.action, test.recommended, rec.Action, rec.Recommended)
+		}
+		if !reflect.DeepEqual(test.hardware, rec.Hardware) {
+			t.Errorf("%d: expected hardware %+v, got %+v", i, test.hardware, rec.Hardware)
+		}
+		if (test.saving == nil) != (rec.Saving == nil) || (test.saving != nil && math.Abs(*test.saving-*rec.Saving) > 1e-9) {
+			t.Errorf("%d: expected saving %s, got %s", i, formatPrice(test.saving), formatPrice(rec.Saving))
+		}
+	}
+}
+
+func TestFlexMonthlyPrice(t *testing.T) {
+	pricing := &oneandone.Pricing{}
+	// Items with other names, e.g. of the disk type, must not be taken for the core, RAM or disk price.
+	err := json.Unmarshal([]byte(`{"pricing_plans": {"servers": {"flexible_server": [
+		{"name": "RAM_EXTRA", "price_net": 100, "unit": "hour"},
+		{"name": "CPU", "price_net": 0.01, "unit": "hour"},
+		{"name": "ram", "price_net": 0.02, "unit": "hour"},
+		{"name": "HDD", "price_net": 0.001, "unit": "hour"}]}}}`), pricing)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if p := flexMonthlyPrice(pricing, 2, 4, 100); p == nil || math.Abs(*p-(0.02+0.08+0.1)*hoursPerMonth) > 1e-9 {
+		t.Errorf("expected monthly price %v, got %s", (0.02+0.08+0.1)*hoursPerMonth, formatPrice(p))
+	}
+	pricing.Plan.Servers.FlexServers = pricing.Plan.Servers.FlexServers[:3]
+	if p := flexMonthlyPrice(pricing, 2, 4, 100); p != nil {
+		t.Errorf("expected no price without a disk price, got %v", *p)
+	}
+}
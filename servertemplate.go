@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/1and1/oneandone-cloudserver-sdk-go"
+	"github.com/codegangsta/cli"
+	"gopkg.in/yaml.v2"
+)
+
+var templateNameFormat = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Settings of a new server stored as YAML in the configuration directory.
+// The keys are the names of the 'server create' options.
+type serverTemplate struct {
+	Name               string   `yaml:"name"`
+	Description        string   `yaml:"desc,omitempty"`
+	FixedSizeId        string   `yaml:"fixsizeid,omitempty"`
+	BaremetalModelId   string   `yaml:"modelid,omitempty"`
+	Cpu                int      `yaml:"cpu,omitempty"`
+	Cores              int      `yaml:"cores,omitempty"`
+	Ram                float32  `yaml:"ram,omitempty"`
+	HddSize            int      `yaml:"hdsize,omitempty"`
+	ApplianceId        string   `yaml:"osid,omitempty"`
+	DatacenterId       string   `yaml:"datacenterid,omitempty"`
+	FirewallPolicyId   string   `yaml:"firewallid,omitempty"`
+	LoadBalancerId     string   `yaml:"loadbalancerid,omitempty"`
+	MonitoringPolicyId string   `yaml:"monitorpolicyid,omitempty"`
+	SSHKeys            []string `yaml:"sshkeyid,omitempty"`
+	SSHPassword        *bool    `yaml:"sshpassword,omitempty"`
+	PrivateNetworkId   string   `yaml:"pnetid,omitempty"`
+	Ipv6Range          string   `yaml:"ipv6range,omitempty"`
+	PowerOn            *bool    `yaml:"poweron,omitempty"`
+}
+
+func serverTemplatePath(name string) string {
+	if !templateNameFormat.MatchString(name) {
+		exitOnError(fmt.Errorf("invalid template name '%s', use letters, digits, '.', '_' and '-' only", name))
+	}
+	return filepath.Join(getConfigDir(), "server-templates", name+".yaml")
+}
+
+func loadServerTemplate(name string) *serverTemplate {
+	content, err := ioutil.ReadFile(serverTemplatePath(name))
+	if os.IsNotExist(err) {
+		exitOnError(fmt.Errorf("server template '%s' not found", name))
+	}
+	exitOnError(err)
+	template := &serverTemplate{}
+	exitOnError(yaml.UnmarshalStrict(content, template))
+	template.Name = name
+	return template
+}
+
+// Overrides the template settings with the options given on the command line.
+func (t *serverTemplate) applyFlags(ctx *cli.Context) {
+	setString := func(flag string, value *string) {
+		if ctx.IsSet(flag) {
+			*value = ctx.String(flag)
+		}
+	}
+	setBool := func(flag string, value **bool) {
+		if ctx.IsSet(flag) {
+			v := ctx.Bool(flag)
+			*value = &v
+		}
+	}
+
+	// Hardware options override single settings. Choosing a fixed size or baremetal model
+	// replaces flexible hardware and vice versa, as only one kind of hardware is used.
+	switch {
+	case ctx.IsSet("modelid"):
+		t.BaremetalModelId, t.FixedSizeId = ctx.String("modelid"), ""
+		t.Cpu, t.Cores, t.Ram, t.HddSize = 0, 0, 0, 0
+	case ctx.IsSet("fixsizeid"):
+		t.FixedSizeId, t.BaremetalModelId = ctx.String("fixsizeid"), ""
+		t.Cpu, t.Cores, t.Ram, t.HddSize = 0, 0, 0, 0
+	case ctx.IsSet("cpu") || ctx.IsSet("cores") || ctx.IsSet("ram") || ctx.IsSet("hdsize"):
+		t.FixedSizeId, t.BaremetalModelId = "", ""
+	}
+	if ctx.IsSet("cpu") {
+		t.Cpu = stringFlag2Int(ctx, "cpu")
+	}
+	if ctx.IsSet("cores") {
+		t.Cores = stringFlag2Int(ctx, "cores")
+	}
+	if ctx.IsSet("ram") {
+		t.Ram = stringFlag2Float32(ctx, "ram")
+	}
+	if ctx.IsSet("hdsize") {
+		t.HddSize = stringFlag2Int(ctx, "hdsize")
+	}
+	setString("desc", &t.Description)
+	setString("osid", &t.ApplianceId)
+	setString("datacenterid", &t.DatacenterId)
+	setString("firewallid", &t.FirewallPolicyId)
+	setString("loadbalancerid", &t.LoadBalancerId)
+	setString("monitorpolicyid", &t.MonitoringPolicyId)
+	setString("pnetid", &t.PrivateNetworkId)
+	setString("ipv6range", &t.Ipv6Range)
+	setBool("sshpassword", &t.SSHPassword)
+	setBool("poweron", &t.PowerOn)
+	if ctx.IsSet("sshkeyid") {
+		t.SSHKeys = ctx.StringSlice("sshkeyid")
+	}
+}
+
+// Returns the option of a setting given on the command line, or the template it is taken from, for error messages.
+func (t *serverTemplate) settingName(ctx *cli.Context) func(string) string {
+	return func(setting string) string {
+		if t.Name == "" || ctx.IsSet(setting) {
+			return flagSettingName(setting)
+		}
+		return fmt.Sprintf("%s of template '%s'", setting, t.Name)
+	}
+}
+
+func (t *serverTemplate) hardware() oneandone.Hardware {
+	switch {
+	case t.BaremetalModelId != "":
+		return oneandone.Hardware{BaremetalModelId: t.BaremetalModelId}
+	case t.FixedSizeId != "":
+		return oneandone.Hardware{FixedInsSizeId: t.FixedSizeId}
+	}
+	return oneandone.Hardware{
+		Vcores:            t.Cpu,
+		CoresPerProcessor: t.Cores,
+		Ram:               t.Ram,
+		Hdds: []oneandone.Hdd{
+			oneandone.Hdd{
+				Size:   t.HddSize,
+				IsMain: true,
+			},
+		},
+	}
+}
+
+func (t *serverTemplate) hardwareSummary() string {
+	switch {
+	case t.BaremetalModelId != "":
+		return "baremetal " + t.BaremetalModelId
+	case t.FixedSizeId != "":
+		return "fixed size " + t.FixedSizeId
+	}
+	return fmt.Sprintf("%d CPU x %d cores, %s GB RAM, %d GB HDD",
+		t.Cpu, t.Cores, strconv.FormatFloat(float64(t.Ram), 'f', -1, 32), t.HddSize)
+}
+
+// Returns the server request for the template. Name, host name, public IP and SSH keys are set by the caller.
+func (t *serverTemplate) request() *oneandone.ServerRequest {
+	req := &oneandone.ServerRequest{
+		Description:        t.Description,
+		ApplianceId:        t.ApplianceId,
+		Hardware:           t.hardware(),
+		PowerOn:            t.PowerOn == nil || *t.PowerOn,
+		FirewallPolicyId:   t.FirewallPolicyId,
+		LoadBalancerId:     t.LoadBalancerId,
+		MonitoringPolicyId: t.MonitoringPolicyId,
+		DatacenterId:       t.DatacenterId,
+		SSHPassword:        t.SSHPassword,
+		PrivateNetworkId:   t.PrivateNetworkId,
+		Ipv6Range:          t.Ipv6Range,
+	}
+	if t.BaremetalModelId != "" {
+		req.ServerType = "baremetal"
+	}
+	return req
+}
+
+// Takes the settings of an existing server that can be reused for new servers.
+func newServerTemplateFromServer(server *oneandone.Server, sshKeys []oneandone.SSHKey) *serverTemplate {
+	t := &serverTemplate{Description: server.Description, Ipv6Range: server.Ipv6Range}
+	if hw := server.Hardware; hw != nil {
+		switch {
+		case server.ServerType == "baremetal" && hw.BaremetalModelId != nil:
+			t.BaremetalModelId = fmt.Sprint(hw.BaremetalModelId)
+		case hw.FixedInsSizeId != "" && hw.FixedInsSizeId != "0":
+			t.FixedSizeId = hw.FixedInsSizeId
+		default:
+			t.Cpu, t.Cores, t.Ram = hw.Vcores, hw.CoresPerProcessor, hw.Ram
+			for _, hdd := range hw.Hdds {
+				if hdd.IsMain {
+					t.HddSize = hdd.Size
+				}
+			}
+		}
+	}
+	if server.Image != nil {
+		t.ApplianceId = server.Image.Id
+	}
+	if server.Datacenter != nil {
+		t.DatacenterId = server.Datacenter.Id
+	}
+	if server.MonPolicy != nil {
+		t.MonitoringPolicyId = server.MonPolicy.Id
+	}
+	for _, ip := range server.Ips {
+		if ip.Firewall != nil && t.FirewallPolicyId == "" {
+			t.FirewallPolicyId = ip.Firewall.Id
+		}
+		if len(ip.LoadBalancers) > 0 && t.LoadBalancerId == "" {
+			t.LoadBalancerId = ip.LoadBalancers[0].Id
+		}
+	}
+	if len(server.PrivateNets) > 0 {
+		t.PrivateNetworkId = server.PrivateNets[0].Id
+	}
+	for _, key := range sshKeys {
+		if key.Servers == nil {
+			continue
+		}
+		for _, s := range *key.Servers {
+			if s.Id == server.Id {
+				t.SSHKeys = append(t.SSHKeys, key.Id)
+			}
+		}
+	}
+	return t
+}
+
+func saveServerTemplate(ctx *cli.Context) {
+	name := getRequiredOption(ctx, "name")
+	fileName := serverTemplatePath(name)
+
+	template := &serverTemplate{}
+	if ctx.String("id") != "" {
+		server, err := api.GetServer(ctx.String("id"))
+		exitOnError(err)
+		sshKeys, err := api.ListSSHKeys()
+		exitOnError(err)
+		template = newServerTemplateFromServer(server, sshKeys)
+	}
+	template.applyFlags(ctx)
+	template.Name = name
+
+	content, err := yaml.Marshal(template)
+	exitOnError(err)
+	exitOnError(os.MkdirAll(filepath.Dir(fileName), 0700))
+	exitOnError(ioutil.WriteFile(fileName, content, 0600))
+	fmt.Printf("Server template '%s' saved to %s\n", name, fileName)
+}
+
+func listServerTemplates(ctx *cli.Context) {
+	files, err := filepath.Glob(filepath.Join(getConfigDir(), "server-templates", "*.yaml"))
+	exitOnError(err)
+	var templates []*serverTemplate
+	for _, file := range files {
+		templates = append(templates, loadServerTemplate(strings.TrimSuffix(filepath.Base(file), ".yaml")))
+	}
+	data := make([][]string, len(templates))
+	for i, t := range templates {
+		data[i] = []string{t.Name, t.hardwareSummary(), t.ApplianceId, t.DatacenterId, t.Description}
+	}
+	header := []string{"Name", "Hardware", "Appliance ID", "Data Center ID", "Description"}
+	output(ctx, templates, "", false, &header, &data)
+}
+
+func showServerTemplate(ctx *cli.Context) {
+	template := loadServerTemplate(getRequiredOption(ctx, "name"))
+	content, err := yaml.Marshal(template)
+	exitOnError(err)
+	fmt.Print(string(content))
+}
+
+func deleteServerTemplate(ctx *cli.Context) {
+	name := getRequiredOption(ctx, "name")
+	err := os.Remove(serverTemplatePath(name))
+	if os.IsNotExist(err) {
+		exitOnError(fmt.Errorf("server template '%s' not found", name))
+	}
+	exitOnError(err)
+	fmt.Printf("Server template '%s' deleted.\n", name)
+}
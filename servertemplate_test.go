@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+
+	"github.com/codegangsta/cli"
+)
+
+func newTemplateContext(t *testing.T, args ...string) *cli.Context {
+	set := flag.NewFlagSet("create", flag.ContinueOnError)
+	for _, name := range []string{"fixsizeid", "modelid", "cpu", "cores", "ram", "hdsize", "osid", "desc",
+		"datacenterid", "firewallid", "loadbalancerid", "monitorpolicyid", "pnetid", "ipv6range"} {
+		set.String(name, "", "")
+	}
+	set.Bool("sshpassword", false, "")
+	set.Bool("poweron", false, "")
+	set.Var(&cli.StringSlice{}, "sshkeyid", "")
+	if err := set.Parse(args); err != nil {
+		t.Fatal(err.Error())
+	}
+	return cli.NewContext(nil, set, nil)
+}
+
+func TestServerTemplateApplyFlags(t *testing.T) {
+	flex := serverTemplate{Cpu: 4, Cores: 2, Ram: 8, HddSize: 80, ApplianceId: "A1", DatacenterId: "DE"}
+	tests := []struct {
+		template serverTemplate
+		args     []string
+		expected serverTemplate
+	}{
+		{flex, []string{"--ram", "16"}, serverTemplate{Cpu: 4, Cores: 2, Ram: 16, HddSize: 80, ApplianceId: "A1", DatacenterId: "DE"}},
+		{flex, []string{"--cpu", "8", "--hdsize", "100", "--datacenterid", "US"},
+			serverTemplate{Cpu: 8, Cores: 2, Ram: 8, HddSize: 100, ApplianceId: "A1", DatacenterId: "US"}},
+		{flex, []string{"--fixsizeid", "M"}, serverTemplate{FixedSizeId: "M", ApplianceId: "A1", DatacenterId: "DE"}},
+		{serverTemplate{FixedSizeId: "M", ApplianceId: "A1"}, []string{"--modelid", "B1"}, serverTemplate{BaremetalModelId: "B1", ApplianceId: "A1"}},
+		{serverTemplate{FixedSizeId: "M", ApplianceId: "A1"}, []string{"--osid", "A2"}, serverTemplate{FixedSizeId: "M", ApplianceId: "A2"}},
+		{serverTemplate{FixedSizeId: "M"}, []string{"--cpu", "2", "--cores", "1", "--ram", "4", "--hdsize", "40"},
+			serverTemplate{Cpu: 2, Cores: 1, Ram: 4, HddSize: 40}},
+	}
+	for _, test := range tests {
+		template := test.template
+		template.applyFlags(newTemplateContext(t, test.args...))
+		if !reflect.DeepEqual(test.expected, template) {
+			t.Errorf("%v: expected template %+v, got %+v", test.args, test.expected, template)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/1and1/oneandone-cloudserver-sdk-go"
+)
+
+// Limits of flexible server hardware and hard disks. The maximum processors and RAM
+// are raised by larger hardware found in the fixed-size catalog.
+const (
+	defaultMaxVcores = 16
+	defaultMaxRam    = 128
+	ramStep          = 0.5
+	minHddSize       = 20
+	maxHddSize       = 2000
+	hddSizeStep      = 10
+	catalogCacheTTL  = 24 * time.Hour
+)
+
+// Reads the catalog from the cache file if it is younger than catalogCacheTTL, otherwise
+// or if refresh is set, fetches it from the API and updates the cache file.
+// Without a cache directory, the catalog is always fetched.
+func loadCatalog(name string, refresh bool, fetch func() (interface{}, error), catalog interface{}) {
+	cacheDir := getCacheDir()
+	fileName := filepath.Join(cacheDir, name+".json")
+	if info, err := os.Stat(fileName); !refresh && cacheDir != "" && err == nil && time.Since(info.ModTime()) < catalogCacheTTL {
+		if content, err := ioutil.ReadFile(fileName); err == nil && json.Unmarshal(content, catalog) == nil {
+			return
+		}
+	}
+	result, err := fetch()
+	exitOnError(err)
+	content, err := json.Marshal(result)
+	exitOnError(err)
+	exitOnError(json.Unmarshal(content, catalog))
+	// The cache is only an optimization, failing to write it is not an error.
+	if cacheDir != "" && os.MkdirAll(filepath.Dir(fileName), 0700) == nil {
+		ioutil.WriteFile(fileName, content, 0600)
+	}
+}
+
+// Returns the appliance from the cached catalog, refreshing the catalog once if the appliance is not in it.
+func getCachedAppliance(id string) *oneandone.ServerAppliance {
+	for _, refresh := range []bool{false, true} {
+		var appliances []oneandone.ServerAppliance
+		loadCatalog("server-appliances", refresh, func() (interface{}, error) { return api.ListServerAppliances() }, &appliances)
+		for i := range appliances {
+			if appliances[i].Id == id {
+				return &appliances[i]
+			}
+		}
+	}
+	return nil
+}
+
+func getCachedFixedSize(id string) *oneandone.FixedInstanceInfo {
+	for _, refresh := range []bool{false, true} {
+		var sizes []oneandone.FixedInstanceInfo
+		loadCatalog("fixed-sizes", refresh, func() (interface{}, error) { return api.ListFixedInstanceSizes() }, &sizes)
+		for i := range sizes {
+			if sizes[i].Id == id {
+				return &sizes[i]
+			}
+		}
+	}
+	return nil
+}
+
+func getCachedBaremetalModel(id string) *oneandone.BaremetalModel {
+	for _, refresh := range []bool{false, true} {
+		var models []oneandone.BaremetalModel
+		loadCatalog("baremetal-models", refresh, func() (interface{}, error) { return api.ListBaremetalModels() }, &models)
+		for i := range models {
+			if models[i].Id == id {
+				return &models[i]
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the maximum processors and RAM of flexible servers.
+func getFlexLimits() (maxVcores int, maxRam float32) {
+	maxVcores, maxRam = defaultMaxVcores, defaultMaxRam
+	var sizes []oneandone.FixedInstanceInfo
+	loadCatalog("fixed-sizes", false, func() (interface{}, error) { return api.ListFixedInstanceSizes() }, &sizes)
+	for _, size := range sizes {
+		if hw := size.Hardware; hw != nil {
+			if hw.Vcores > maxVcores {
+				maxVcores = hw.Vcores
+			}
+			if hw.Ram > maxRam {
+				maxRam = hw.Ram
+			}
+		}
+	}
+	return maxVcores, maxRam
+}
+
+// Returns the command line option of a setting for error messages.
+func flagSettingName(setting string) string {
+	return "--" + setting
+}
+
+func formatGB(size float32) string {
+	return strconv.FormatFloat(float64(size), 'f', -1, 32)
+}
+
+// Checks the number of processors, cores per processor and RAM of a flexible server.
+// The settings are named in the messages by settingName, e.g. --cpu or the template they are taken from.
+func validateFlexHardware(vcores, coresPerProcessor int, ram float32, settingName func(string) string) []string {
+	var errs []string
+	maxVcores, maxRam := getFlexLimits()
+	if vcores < 1 || vcores > maxVcores {
+		errs = append(errs, fmt.Sprintf("%s must be between 1 and %d, got %d", settingName("cpu"), maxVcores, vcores))
+	}
+	if coresPerProcessor < 1 || (vcores > 0 && coresPerProcessor > vcores) {
+		errs = append(errs, fmt.Sprintf("%s must be between 1 and %d, the value of %s, got %d",
+			settingName("cores"), vcores, settingName("cpu"), coresPerProcessor))
+	} else if vcores > 0 && vcores%coresPerProcessor != 0 {
+		divisor := coresPerProcessor
+		for vcores%divisor != 0 {
+			divisor--
+		}
+		errs = append(errs, fmt.Sprintf("%s must be a multiple of %s, got %d and %d, e.g. %d cores per processor",
+			settingName("cpu"), settingName("cores"), vcores, coresPerProcessor, divisor))
+	}
+	if ram < ramStep || ram > maxRam {
+		errs = append(errs, fmt.Sprintf("%s must be between %s and %s GB, got %s",
+			settingName("ram"), formatGB(ramStep), formatGB(maxRam), formatGB(ram)))
+	} else if steps := float64(ram) / ramStep; steps != math.Floor(steps) {
+		errs = append(errs, fmt.Sprintf("%s must be a multiple of %s GB, e.g. %s",
+			settingName("ram"), formatGB(ramStep), formatGB(float32(math.Ceil(steps)*ramStep))))
+	}
+	return errs
+}
+
+func validateHddSize(name string, size int) []string {
+	if size < minHddSize || size > maxHddSize || size%hddSizeStep != 0 {
+		return []string{fmt.Sprintf("%s must be between %d and %d GB and a multiple of %d, got %d",
+			name, minHddSize, maxHddSize, hddSizeStep, size)}
+	}
+	return nil
+}
+
+// Exits with all the errors found, one per line.
+func exitOnValidationErrors(errs []string) {
+	if len(errs) > 0 {
+		exitOnError(fmt.Errorf("%s", strings.Join(errs, "\n")))
+	}
+}
+
+// Checks the hardware and appliance of a new server against the cached catalogs before it is created.
+func validateServerRequest(req *oneandone.ServerRequest, settingName func(string) string) {
+	var errs []string
+	serverType, hddSize := "cloud", 0
+	hw := req.Hardware
+	switch {
+	case hw.BaremetalModelId != nil && hw.BaremetalModelId != "":
+		serverType = "baremetal"
+		id := fmt.Sprint(hw.BaremetalModelId)
+		model := getCachedBaremetalModel(id)
+		if model == nil {
+			errs = append(errs, fmt.Sprintf("baremetal model '%s' not found, see 'oneandone server baremetalmodels'", id))
+		} else if model.Hardware != nil && len(model.Hardware.Hdds) > 0 {
+			hddSize = model.Hardware.Hdds[0].Size
+		}
+	case hw.FixedInsSizeId != "":
+		size := getCachedFixedSize(hw.FixedInsSizeId)
+		if size == nil {
+			errs = append(errs, fmt.Sprintf("fixed size '%s' not found, see 'oneandone server fixedsizes'", hw.FixedInsSizeId))
+		} else if size.Hardware != nil && len(size.Hardware.Hdds) > 0 {
+			hddSize = size.Hardware.Hdds[0].Size
+		}
+	default:
+		errs = append(errs, validateFlexHardware(hw.Vcores, hw.CoresPerProcessor, hw.Ram, settingName)...)
+		for _, hdd := range hw.Hdds {
+			errs = append(errs, validateHddSize(settingName("hdsize"), hdd.Size)...)
+			if hdd.IsMain {
+				hddSize = hdd.Size
+			}
+		}
+	}
+
+	if req.ApplianceId != "" {
+		appliance := getCachedAppliance(req.ApplianceId)
+		switch {
+		case appliance == nil:
+			errs = append(errs, fmt.Sprintf("server appliance '%s' not found, see 'oneandone appliance list'", req.ApplianceId))
+		default:
+			compatible := len(appliance.ServerTypeCompatibility) == 0
+			for _, t := range appliance.ServerTypeCompatibility {
+				if strings.EqualFold(t, serverType) {
+					compatible = true
+				}
+			}
+			if !compatible {
+				errs = append(errs, fmt.Sprintf("appliance '%s' can only be installed on %s servers, not on %s servers",
+					appliance.Name, strings.Join(appliance.ServerTypeCompatibility, " or "), serverType))
+			}
+			if hddSize > 0 && hddSize < appliance.MinHddSize {
+				errs = append(errs, fmt.Sprintf("appliance '%s' requires a hard disk of at least %d GB, the server has %d GB",
+					appliance.Name, appliance.MinHddSize, hddSize))
+			}
+		}
+	}
+	exitOnValidationErrors(errs)
+}
+
+// Checks a hardware update of the server. Options not given keep the current hardware of the server.
+func validateHardwareUpdate(server *oneandone.Server, hw *oneandone.Hardware) {
+	if hw.FixedInsSizeId != "" {
+		size := getCachedFixedSize(hw.FixedInsSizeId)
+		if size == nil {
+			exitOnError(fmt.Errorf("fixed size '%s' not found, see 'oneandone server fixedsizes'", hw.FixedInsSizeId))
+		}
+		return
+	}
+	if server.ServerType == "baremetal" {
+		exitOnError(fmt.Errorf("the hardware of baremetal server '%s' cannot be changed", server.Name))
+	}
+	current := server.Hardware
+	if current == nil {
+		current = &oneandone.Hardware{}
+	}
+	vcores, cores, ram := current.Vcores, current.CoresPerProcessor, current.Ram
+	given := make(map[string]bool)
+	if hw.Vcores > 0 {
+		vcores, given["cpu"] = hw.Vcores, true
+	}
+	if hw.CoresPerProcessor > 0 {
+		cores, given["cores"] = hw.CoresPerProcessor, true
+	}
+	if hw.Ram > 0 {
+		ram, given["ram"] = hw.Ram, true
+	}
+	settingName := func(setting string) string {
+		if given[setting] {
+			return flagSettingName(setting)
+		}
+		return fmt.Sprintf("current %s of server '%s'", setting, server.Name)
+	}
+	exitOnValidationErrors(validateFlexHardware(vcores, cores, ram, settingName))
+}
@@ -25,12 +25,10 @@ func init() {
 				{
 					Name:  "access",
 					Usage: "Shows access credentials or changes password for shared storages.",
-					Flags: []cli.Flag{
-						cli.StringFlag{
-							Name:  "newpass",
-							Usage: "New password for accessing the shared storages.",
-						},
-					},
+					Flags: secretFlags(cli.StringFlag{
+						Name:  "newpass",
+						Usage: "New password for accessing the shared storages.",
+					}),
 					Action: accessShDrives,
 				},
 				{
@@ -96,6 +94,44 @@ func init() {
 					Flags:  queryFlags,
 					Action: listShDrives,
 				},
+				{
+					Name:  "mountconfig",
+					Usage: "Generates mount definition of shared storage for server.",
+					Flags: []cli.Flag{
+						ssDriveIdFlag,
+						cli.StringFlag{
+							Name:  "serverid",
+							Usage: "ID of the server attached to the shared storage.",
+						},
+						cli.StringFlag{
+							Name:  "format",
+							Usage: "Mount definition format: " + mountConfigFormats + ".",
+						},
+						cli.StringFlag{
+							Name:  "protocol",
+							Value: "nfs",
+							Usage: "Protocol used to mount the shared storage on Linux: nfs or cifs.",
+						},
+						cli.StringFlag{
+							Name:  "mountpoint",
+							Usage: "Mount point on the server. Default is /mnt/[shared storage name].",
+						},
+						cli.StringFlag{
+							Name:  "drive",
+							Value: "Z:",
+							Usage: "Drive letter for windows-netuse format.",
+						},
+						cli.BoolFlag{
+							Name:  "kerberos",
+							Usage: "Mount NFS with Kerberos security and write the keytab file.",
+						},
+						cli.StringFlag{
+							Name:  "keytab",
+							Usage: "Path to write the Kerberos keytab to. Default is [shared storage name].keytab.",
+						},
+					},
+					Action: generateMountConfig,
+				},
 				{
 					Name:   "rm",
 					Usage:  "Deletes shared storage drive.",
@@ -120,6 +156,49 @@ func init() {
 					Flags:  []cli.Flag{ssDriveIdFlag},
 					Action: listShDriveServers,
 				},
+				{
+					Name:  "watch",
+					Usage: "Reports utilization of shared storages and optionally grows them.",
+					Flags: []cli.Flag{
+						cli.StringSliceFlag{
+							Name:  "id, i",
+							Usage: "IDs of the shared storages to watch. All storages are watched if not set.",
+						},
+						cli.IntFlag{
+							Name:  "warn",
+							Value: 80,
+							Usage: "Warning threshold of used space in percent.",
+						},
+						cli.IntFlag{
+							Name:  "crit",
+							Value: 90,
+							Usage: "Critical threshold of used space in percent. Exits with status 1 if exceeded.",
+						},
+						cli.IntFlag{
+							Name:  "interval",
+							Usage: "Repeat the check every given number of seconds. The check runs once if not set.",
+						},
+						cli.BoolFlag{
+							Name:  "autogrow",
+							Usage: "Enlarge storages whose usage exceeds the warning threshold.",
+						},
+						cli.IntFlag{
+							Name:  "step",
+							Value: 50,
+							Usage: "Size in GB to add to a storage when growing, multiple of 50.",
+						},
+						cli.IntFlag{
+							Name:  "max",
+							Value: 2000,
+							Usage: "Maximum size in GB up to which storages are grown, multiple of 50.",
+						},
+						cli.BoolFlag{
+							Name:  "dryrun",
+							Usage: "Only log the growth decisions without changing any storage.",
+						},
+					},
+					Action: watchShDrives,
+				},
 				{
 					Name:  "update",
 					Usage: "Updates shared storage.",
@@ -260,9 +339,12 @@ func detachShDrive(ctx *cli.Context) {
 }
 
 func accessShDrives(ctx *cli.Context) {
-	if ctx.IsSet("newpass") {
-		storage, err := api.UpdateSharedStorageCredentials(ctx.String("newpass"))
+	if password, generated := getSecret(ctx, "newpass", false); password != "" {
+		storage, err := api.UpdateSharedStorageCredentials(password)
 		exitOnError(err)
+		if generated {
+			saveSecret(ctx, "shared storage access", "", password)
+		}
 		output(ctx, storage, okWaitMessage, false, nil, nil)
 		return
 	}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/1and1/oneandone-cloudserver-sdk-go"
+	"github.com/codegangsta/cli"
+)
+
+const mountConfigFormats = "fstab, systemd, autofs or windows-netuse"
+
+var unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// Mount definition of a shared storage for one server.
+type shDriveMount struct {
+	Source     string
+	MountPoint string
+	Options    []string
+}
+
+// Converts a CIFS path such as \\10.4.141.250\ss1234 to the //10.4.141.250/ss1234 form used on Linux.
+func linuxCifsPath(cifsPath string) string {
+	return strings.Replace(cifsPath, `\`, "/", -1)
+}
+
+// Escapes a mount point the way systemd names mount units, e.g. /mnt/my-data -> mnt-my\x2ddata.mount.
+func systemdMountUnit(mountPoint string) string {
+	p := strings.Trim(path.Clean(mountPoint), "/")
+	if p == "" {
+		return "-.mount"
+	}
+	var unit strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch {
+		case c == '/':
+			unit.WriteByte('-')
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_', c == '.' && i > 0:
+			unit.WriteByte(c)
+		default:
+			fmt.Fprintf(&unit, `\x%02x`, c)
+		}
+	}
+	return unit.String() + ".mount"
+}
+
+func getShDriveAccess(siteId string) *oneandone.SharedStorageAccess {
+	credentials, err := api.GetSharedStorageCredentials()
+	exitOnError(err)
+	for i := range credentials {
+		if credentials[i].SiteId == siteId {
+			return &credentials[i]
+		}
+	}
+	if len(credentials) == 1 {
+		return &credentials[0]
+	}
+	exitOnError(fmt.Errorf("no shared storage access credentials found, set a password with 'sharedstorage access --newpass'"))
+	return nil
+}
+
+// Writes the Kerberos keytab from the base64 encoded content of the access credentials.
+func writeKerberosKeytab(access *oneandone.SharedStorageAccess, fileName string) {
+	if access.KerberosContentFile == "" {
+		exitOnError(fmt.Errorf("no Kerberos content is available for the shared storage access"))
+	}
+	keytab, err := base64.StdEncoding.DecodeString(access.KerberosContentFile)
+	exitOnError(err)
+	exitOnError(ioutil.WriteFile(fileName, keytab, 0600))
+}
+
+func generateMountConfig(ctx *cli.Context) {
+	driveId := getRequiredOption(ctx, "id")
+	serverId := getRequiredOption(ctx, "serverid")
+	format := strings.ToLower(getRequiredOption(ctx, "format"))
+	switch format {
+	case "fstab", "systemd", "autofs", "windows-netuse":
+	default:
+		exitOnError(fmt.Errorf("--format must be either %s", mountConfigFormats))
+	}
+	protocol := strings.ToLower(ctx.String("protocol"))
+	if format == "windows-netuse" {
+		protocol = "cifs"
+	}
+	if protocol != "nfs" && protocol != "cifs" {
+		exitOnError(fmt.Errorf("--protocol must be either nfs or cifs"))
+	}
+	kerberos := ctx.Bool("kerberos")
+	if kerberos && protocol != "nfs" {
+		exitOnError(fmt.Errorf("--kerberos is only supported for NFS mounts"))
+	}
+
+	storage, err := api.GetSharedStorage(driveId)
+	exitOnError(err)
+	server, err := api.GetSharedStorageServer(driveId, serverId)
+	exitOnError(err)
+	rights := "ro"
+	if strings.ToUpper(server.Rights) == "RW" {
+		rights = "rw"
+	}
+
+	name := unsafeNameChars.ReplaceAllString(storage.Name, "-")
+	mount := shDriveMount{
+		MountPoint: ctx.String("mountpoint"),
+		Options:    []string{rights},
+	}
+	if mount.MountPoint == "" {
+		mount.MountPoint = "/mnt/" + name
+	}
+
+	var access *oneandone.SharedStorageAccess
+	if protocol == "cifs" || kerberos {
+		access = getShDriveAccess(storage.SiteId)
+	}
+	if protocol == "nfs" {
+		if storage.NfsPath == "" {
+			exitOnError(fmt.Errorf("shared storage %s has no NFS path", storage.Name))
+		}
+		mount.Source = storage.NfsPath
+		mount.Options = append(mount.Options, "hard")
+		if kerberos {
+			keytab := ctx.String("keytab")
+			if keytab == "" {
+				keytab = name + ".keytab"
+			}
+			writeKerberosKeytab(access, keytab)
+			fmt.Printf("# Kerberos keytab written to %s, install it as /etc/krb5.keytab on the server.\n", keytab)
+			mount.Options = append(mount.Options, "sec=krb5")
+		}
+	} else {
+		if storage.CifsPath == "" {
+			exitOnError(fmt.Errorf("shared storage %s has no CIFS path", storage.Name))
+		}
+		mount.Source = linuxCifsPath(storage.CifsPath)
+		mount.Options = append(mount.Options, "credentials=/etc/oneandone/"+name+".cred")
+	}
+	options := strings.Join(mount.Options, ",")
+	// Boot-time mounts must wait for the network and must not block booting.
+	bootOptions := options + ",_netdev,nofail"
+
+	switch format {
+	case "fstab":
+		fmt.Printf("# /etc/fstab\n%s %s %s %s 0 0\n", mount.Source, mount.MountPoint, protocol, bootOptions)
+	case "systemd":
+		unit := systemdMountUnit(mount.MountPoint)
+		fmt.Printf("# /etc/systemd/system/%s\n"+
+			"[Unit]\nDescription=1&1 shared storage %s\nAfter=network-online.target\nWants=network-online.target\n\n"+
+			"[Mount]\nWhat=%s\nWhere=%s\nType=%s\nOptions=%s\n\n"+
+			"[Install]\nWantedBy=multi-user.target\n", unit, storage.Name, mount.Source, mount.MountPoint, protocol, bootOptions)
+	case "autofs":
+		source := mount.Source
+		if protocol == "cifs" {
+			source = ":" + source
+		}
+		fmt.Printf("# /etc/auto.master.d/oneandone.autofs\n/- /etc/auto.oneandone\n\n"+
+			"# /etc/auto.oneandone\n%s -fstype=%s,%s %s\n", mount.MountPoint, protocol, options, source)
+	case "windows-netuse":
+		drive := strings.ToUpper(strings.TrimSuffix(ctx.String("drive"), ":")) + ":"
+		if rights == "ro" {
+			fmt.Println("REM The server has read-only access to the shared storage.")
+		}
+		fmt.Printf("net use %s %s /user:%s * /persistent:yes\n", drive, storage.CifsPath, access.UserDomain)
+	}
+
+	if protocol == "cifs" && format != "windows-netuse" {
+		fmt.Printf("\n# /etc/oneandone/%s.cred (mode 0600)\n", name)
+		if i := strings.Index(access.UserDomain, `\`); i >= 0 {
+			fmt.Printf("domain=%s\nusername=%s\n", access.UserDomain[:i], access.UserDomain[i+1:])
+		} else {
+			fmt.Printf("username=%s\n", access.UserDomain)
+		}
+		fmt.Println("password=[shared storage access password]")
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/1and1/oneandone-cloudserver-sdk-go"
+	"github.com/codegangsta/cli"
+)
+
+// Utilization of a shared storage as reported by 'sharedstorage watch'.
+// The used space is nil if the API did not report it.
+type shDriveUsage struct {
+	Id          string   `json:"id"`
+	Name        string   `json:"name"`
+	Size        int      `json:"size"`
+	UsedPct     *float64 `json:"used_percent"`
+	UsedGB      *float64 `json:"used_gb"`
+	Status      string   `json:"status"`
+	NewSize     int      `json:"new_size,omitempty"`
+	PriceChange *float64 `json:"monthly_price_change,omitempty"`
+}
+
+type shDriveGrowth struct {
+	step, max int
+	dryRun    bool
+	pricing   *oneandone.Pricing
+}
+
+func watchShDrives(ctx *cli.Context) {
+	warn := validateIntRange("warn", ctx.Int("warn"), 1, 100)
+	crit := validateIntRange("crit", ctx.Int("crit"), warn, 100)
+	interval := ctx.Int("interval")
+	if interval < 0 {
+		exitOnError(fmt.Errorf("--interval must not be negative"))
+	}
+
+	var growth *shDriveGrowth
+	if ctx.Bool("autogrow") {
+		growth = &shDriveGrowth{
+			step:   validateIntRange("step", ctx.Int("step"), 50, 2000),
+			max:    validateIntRange("max", ctx.Int("max"), 50, 2000),
+			dryRun: ctx.Bool("dryrun"),
+		}
+		if growth.step%50 != 0 || growth.max%50 != 0 {
+			exitOnError(fmt.Errorf("--step and --max must be multiple of 50"))
+		}
+		growth.pricing = getPricing()
+		if growth.pricing.Plan == nil || growth.pricing.Plan.SharedStorage == nil {
+			exitOnError(fmt.Errorf("shared storage pricing is not available, refusing to grow storages"))
+		}
+	}
+
+	ids := getStringSliceOption(ctx, "id", false)
+	for {
+		critical := checkShDrives(ctx, ids, warn, crit, growth)
+		if interval == 0 {
+			if critical > 0 {
+				os.Exit(1)
+			}
+			return
+		}
+		time.Sleep(time.Duration(interval) * time.Second)
+	}
+}
+
+// Reports utilization of the shared storages and grows them if requested.
+// Returns the number of storages above the critical threshold.
+func checkShDrives(ctx *cli.Context, ids []string, warn, crit int, growth *shDriveGrowth) int {
+	var storages []oneandone.SharedStorage
+	if len(ids) > 0 {
+		for _, id := range ids {
+			storage, err := api.GetSharedStorage(id)
+			exitOnError(err)
+			storages = append(storages, *storage)
+		}
+	} else {
+		var err error
+		storages, err = api.ListSharedStorages()
+		exitOnError(err)
+	}
+
+	var usages []*shDriveUsage
+	var logs []string
+	critical := 0
+	for i := range storages {
+		storage := &storages[i]
+		usage := &shDriveUsage{
+			Id:     storage.Id,
+			Name:   storage.Name,
+			Size:   storage.Size,
+			Status: "UNKNOWN",
+		}
+		usages = append(usages, usage)
+		used, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(storage.SizeUsed), "%"), 64)
+		if err != nil {
+			logs = append(logs, fmt.Sprintf("%s %s: used space '%s' is unknown\n",
+				time.Now().Format(time.RFC3339), storage.Name, storage.SizeUsed))
+			continue
+		}
+		usedGB := float64(storage.Size) * used / 100
+		usage.UsedPct, usage.UsedGB, usage.Status = &used, &usedGB, "OK"
+		if used >= float64(crit) {
+			usage.Status = "CRITICAL"
+			critical++
+		} else if used >= float64(warn) {
+			usage.Status = "WARNING"
+		}
+		if growth != nil && used >= float64(warn) {
+			logs = append(logs, growth.grow(storage, usage))
+		}
+	}
+
+	data := make([][]string, len(usages))
+	for i, u := range usages {
+		newSize, priceChange := "", ""
+		if u.NewSize > 0 {
+			newSize = strconv.Itoa(u.NewSize)
+		}
+		if u.PriceChange != nil {
+			priceChange = formatPrice(u.PriceChange)
+		}
+		data[i] = []string{
+			u.Id,
+			u.Name,
+			strconv.Itoa(u.Size),
+			formatUsed(u.UsedPct),
+			formatUsed(u.UsedGB),
+			u.Status,
+			newSize,
+		}
+		if growth != nil {
+			data[i] = append(data[i], priceChange)
+		}
+	}
+	header := []string{"ID", "Name", "Size (GB)", "Used (%)", "Used (GB)", "Status", "New Size (GB)"}
+	if growth != nil {
+		header = append(header, fmt.Sprintf("Monthly Price Change (%s)", growth.pricing.Currency))
+	}
+	message := strings.Join(logs, "")
+	message += fmt.Sprintf("%d of %d shared storages above %d%%, %d above %d%%",
+		countShDrivesAbove(usages, warn), len(usages), warn, critical, crit)
+	if unknown := len(usages) - countShDrivesAbove(usages, 0); unknown > 0 {
+		message += fmt.Sprintf(", usage of %d unknown", unknown)
+	}
+	message += ".\n"
+	output(ctx, usages, message, false, &header, &data)
+	return critical
+}
+
+func formatUsed(used *float64) string {
+	if used == nil {
+		return "-"
+	}
+	return strconv.FormatFloat(*used, 'f', 1, 64)
+}
+
+func countShDrivesAbove(usages []*shDriveUsage, threshold int) int {
+	count := 0
+	for _, u := range usages {
+		if u.UsedPct != nil && *u.UsedPct >= float64(threshold) {
+			count++
+		}
+	}
+	return count
+}
+
+// Returns the gross monthly price of one GB of shared storage. The pricing unit gives the
+// billed size and period, e.g. "50 GB/month" or "GB/hour". ok is false for units not understood.
+func (g *shDriveGrowth) monthlyPricePerGB() (price float64, ok bool) {
+	item := g.pricing.Plan.SharedStorage
+	unit := strings.ToLower(item.Unit)
+	i := strings.Index(unit, "gb")
+	if i < 0 {
+		return 0, false
+	}
+	size := 1.0
+	if fields := strings.Fields(unit[:i]); len(fields) > 0 {
+		if n, err := strconv.ParseFloat(fields[len(fields)-1], 64); err == nil && n > 0 {
+			size = n
+		}
+	}
+	period := unit[i+2:]
+	if !strings.Contains(period, "hour") && !strings.Contains(period, "month") {
+		return 0, false
+	}
+	monthly, ok := monthlyPrice(item.GrossPrice, period)
+	return monthly / size, ok
+}
+
+// Enlarges the storage by one step up to the maximum size and returns a log line describing the decision.
+func (g *shDriveGrowth) grow(storage *oneandone.SharedStorage, usage *shDriveUsage) string {
+	logLine := func(format string, args ...interface{}) string {
+		return time.Now().Format(time.RFC3339) + " " + storage.Name + ": " + fmt.Sprintf(format, args...) + "\n"
+	}
+	usedPct := *usage.UsedPct
+
+	if storage.Size >= g.max {
+		return logLine("%.1f%% used, already at maximum size of %d GB, not growing", usedPct, g.max)
+	}
+	if storage.State != "ACTIVE" {
+		return logLine("%.1f%% used, but storage is %s, not growing", usedPct, storage.State)
+	}
+	newSize := storage.Size + g.step
+	if newSize < storage.MinSizeAllowed {
+		newSize = (storage.MinSizeAllowed + 49) / 50 * 50
+	}
+	if newSize > g.max {
+		newSize = g.max
+	}
+	if newSize < storage.MinSizeAllowed {
+		return logLine("%.1f%% used, cannot grow within --max of %d GB, the minimum size allowed is %d GB",
+			usedPct, g.max, storage.MinSizeAllowed)
+	}
+
+	cost := fmt.Sprintf("+%d GB, monthly price unknown", newSize-storage.Size)
+	if perGB, ok := g.monthlyPricePerGB(); ok {
+		change := perGB * float64(newSize-storage.Size)
+		usage.PriceChange = &change
+		cost = fmt.Sprintf("+%d GB, +%s %s per month", newSize-storage.Size, formatPrice(&change), g.pricing.Currency)
+	}
+	if g.dryRun {
+		return logLine("%.1f%% used, would grow from %d to %d GB (%s)", usedPct, storage.Size, newSize, cost)
+	}
+
+	_, err := api.UpdateSharedStorage(storage.Id, &oneandone.SharedStorageRequest{Size: &newSize})
+	if err != nil {
+		return logLine("%.1f%% used, growing from %d to %d GB failed: %v", usedPct, storage.Size, newSize, err)
+	}
+	usage.NewSize = newSize
+	return logLine("%.1f%% used, growing from %d to %d GB (%s)", usedPct, storage.Size, newSize, cost)
+}
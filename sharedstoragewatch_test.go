@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/1and1/oneandone-cloudserver-sdk-go"
+)
+
+func TestGrowSharedStorage(t *testing.T) {
+	tests := []struct {
+		size, minSize, step, max int
+		newSize                  int
+		log                      string
+	}{
+		{100, 0, 50, 500, 150, "would grow from 100 to 150 GB (+50 GB, +0.10 EUR per month)"},
+		{450, 0, 100, 500, 500, "would grow from 450 to 500 GB"},
+		{100, 220, 50, 500, 250, "would grow from 100 to 250 GB"},
+		// The minimum size is rounded up to 250 GB, which must not exceed --max.
+		{100, 220, 50, 240, 240, "would grow from 100 to 240 GB"},
+		{100, 220, 50, 200, 0, "cannot grow within --max of 200 GB"},
+		{500, 0, 50, 500, 0, "already at maximum size"},
+	}
+	pricing := &oneandone.Pricing{}
+	err := json.Unmarshal([]byte(`{"currency": "EUR", "pricing_plans": {"shared_storage":
+		{"name": "SHARED_STORAGE", "price_gross": 0.1, "unit": "50 GB/month"}}}`), pricing)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, test := range tests {
+		growth := &shDriveGrowth{step: test.step, max: test.max, dryRun: true, pricing: pricing}
+		storage := &oneandone.SharedStorage{Name: "data", Size: test.size, MinSizeAllowed: test.minSize, State: "ACTIVE"}
+		used := 95.0
+		usage := &shDriveUsage{UsedPct: &used}
+		logLine := growth.grow(storage, usage)
+		if !strings.Contains(logLine, test.log) {
+			t.Errorf("%+v: expected log line with '%s', got '%s'", test, test.log, logLine)
+		}
+		if test.newSize == 0 && strings.Contains(logLine, "would grow") {
+			t.Errorf("%+v: expected no growth, got '%s'", test, logLine)
+		}
+	}
+}
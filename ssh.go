@@ -0,0 +1,394 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/1and1/oneandone-cloudserver-sdk-go"
+	"github.com/codegangsta/cli"
+)
+
+var sshOps []cli.Command
+
+func init() {
+	userFlag := cli.StringFlag{
+		Name:  "user, l",
+		Value: "root",
+		Usage: "Remote user to log in as.",
+	}
+	identityFlag := cli.StringFlag{
+		Name:  "identity, i",
+		Usage: "Private key file. By default a key in ~/.ssh matching an SSH key of the account is used.",
+	}
+	ipv6Flag := cli.BoolFlag{
+		Name:  "ipv6, 6",
+		Usage: "Connect to the IPv6 address of the server.",
+	}
+	sshOps = []cli.Command{
+		{
+			Name:        "ssh",
+			HelpName:    appName + " ssh",
+			Description: "Connects to 1&1 servers by name using the system ssh client",
+			Usage:       "Opens SSH session or runs command on servers.",
+			ArgsUsage:   "<server> [-- command]",
+			Flags: []cli.Flag{
+				userFlag,
+				identityFlag,
+				ipv6Flag,
+				cli.IntFlag{
+					Name:  "parallel",
+					Value: 10,
+					Usage: "Maximum number of servers the command runs on at the same time.",
+				},
+			},
+			Action: runSsh,
+		},
+		{
+			Name:        "scp",
+			HelpName:    appName + " scp",
+			Description: "Copies files to and from 1&1 servers by name using the system scp client",
+			Usage:       "Copies files to and from a server.",
+			ArgsUsage:   "<source>... <target>",
+			Flags: []cli.Flag{
+				userFlag,
+				identityFlag,
+				ipv6Flag,
+				cli.BoolFlag{
+					Name:  "recursive, r",
+					Usage: "Copy directories recursively.",
+				},
+			},
+			Action: runScp,
+		},
+	}
+}
+
+// Normalizes MD5 fingerprints so that 'MD5:AB:CD:...' and 'abcd...' compare equal.
+func normalizeFingerprint(fingerprint string) string {
+	fingerprint = strings.TrimPrefix(strings.TrimSpace(fingerprint), "MD5:")
+	return strings.ToLower(strings.Replace(fingerprint, ":", "", -1))
+}
+
+// Returns the private key files in ~/.ssh by the MD5 fingerprint of their public keys.
+func localSshIdentities() map[string]string {
+	identities := make(map[string]string)
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return identities
+	}
+	files, _ := filepath.Glob(filepath.Join(home, ".ssh", "*.pub"))
+	for _, pubFile := range files {
+		privateFile := strings.TrimSuffix(pubFile, ".pub")
+		if _, err := os.Stat(privateFile); err != nil {
+			continue
+		}
+		content, err := ioutil.ReadFile(pubFile)
+		if err != nil {
+			continue
+		}
+		if key, err := parseSshPublicKey(string(content)); err == nil {
+			identities[normalizeFingerprint(key.Md5)] = privateFile
+		}
+	}
+	return identities
+}
+
+// Picks a local private key for the server. Keys recorded as installed on the server are preferred
+// over the other keys of the account. Returns an empty string if no local key matches.
+func findSshIdentity(sshKeys []oneandone.SSHKey, identities map[string]string, serverId string) string {
+	fallback := ""
+	for _, key := range sshKeys {
+		privateFile, ok := identities[normalizeFingerprint(key.Md5)]
+		if !ok {
+			continue
+		}
+		if key.Servers != nil {
+			for _, s := range *key.Servers {
+				if s.Id == serverId {
+					return privateFile
+				}
+			}
+		}
+		if fallback == "" {
+			fallback = privateFile
+		}
+	}
+	return fallback
+}
+
+// Resolves the selector to servers. An exact server name or ID selects one server, otherwise
+// the selector is matched as a glob pattern against server names and inventory groups, e.g. 'web-*' or 'tag_db'.
+func selectServers(servers []oneandone.Server, selector string) []*oneandone.Server {
+	for i := range servers {
+		if servers[i].Name == selector || servers[i].Id == selector {
+			return []*oneandone.Server{&servers[i]}
+		}
+	}
+	hosts, groups := newInventory(servers)
+	inGroup := make(map[string]bool)
+	for g, members := range groups {
+		if ok, _ := path.Match(selector, g); ok {
+			for _, m := range members {
+				inGroup[m] = true
+			}
+		}
+	}
+	var selected []*oneandone.Server
+	for _, h := range hosts {
+		if ok, _ := path.Match(selector, h.server.Name); ok || inGroup[h.name] {
+			selected = append(selected, h.server)
+		}
+	}
+	return selected
+}
+
+func getSshAddress(server *oneandone.Server, ipv6 bool) (string, error) {
+	address := getServerPrimaryIp(server)
+	if ipv6 {
+		address = ""
+		for _, ip := range server.Ips {
+			if ip.Type == "IPV6" {
+				address = ip.Ip
+				break
+			}
+		}
+	}
+	if address == "" {
+		return "", fmt.Errorf("server %s has no public IP address", server.Name)
+	}
+	return address, nil
+}
+
+// Target of an ssh connection to one server.
+type sshTarget struct {
+	server   *oneandone.Server
+	address  string
+	identity string
+}
+
+func newSshTargets(ctx *cli.Context, servers []*oneandone.Server) []*sshTarget {
+	identities := map[string]string{}
+	var sshKeys []oneandone.SSHKey
+	if ctx.String("identity") == "" {
+		identities = localSshIdentities()
+		if len(identities) > 0 {
+			var err error
+			sshKeys, err = api.ListSSHKeys()
+			exitOnError(err)
+		}
+	}
+	targets := make([]*sshTarget, len(servers))
+	for i, server := range servers {
+		address, err := getSshAddress(server, ctx.Bool("ipv6"))
+		exitOnError(err)
+		identity := ctx.String("identity")
+		if identity == "" {
+			identity = findSshIdentity(sshKeys, identities, server.Id)
+		}
+		targets[i] = &sshTarget{server: server, address: address, identity: identity}
+	}
+	return targets
+}
+
+func (t *sshTarget) args(batch bool) []string {
+	var args []string
+	if t.identity != "" {
+		args = append(args, "-i", t.identity)
+	}
+	if batch {
+		args = append(args, "-o", "BatchMode=yes")
+	}
+	return args
+}
+
+// Writer prefixing every complete line with the server name. Lines of
+// concurrent writers are not interleaved.
+type prefixWriter struct {
+	prefix string
+	out    io.Writer
+	mutex  *sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buffer.Write(p)
+	for {
+		i := bytes.IndexByte(w.buffer.Bytes(), '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		w.writeLine(w.buffer.Next(i + 1))
+	}
+}
+
+func (w *prefixWriter) Flush() {
+	if w.buffer.Len() > 0 {
+		w.writeLine(append(w.buffer.Bytes(), '\n'))
+		w.buffer.Reset()
+	}
+}
+
+func (w *prefixWriter) writeLine(line []byte) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	fmt.Fprintf(w.out, "%s%s", w.prefix, line)
+}
+
+// Runs the command, connected to the terminal, and exits with its exit code if it fails.
+func execInteractive(name string, args []string) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			os.Exit(exitErr.ExitCode())
+		}
+		exitOnError(err)
+	}
+}
+
+func runSsh(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) == 0 {
+		exitOnError(fmt.Errorf("server name is required"))
+	}
+	command := args[1:]
+	if len(command) > 0 && command[0] == "--" {
+		command = command[1:]
+	}
+	parallel := validateIntRange("parallel", ctx.Int("parallel"), 1, 100)
+
+	ensureClient(ctx)
+	all, err := api.ListServers()
+	exitOnError(err)
+	servers := selectServers(all, args[0])
+	if len(servers) == 0 {
+		exitOnError(fmt.Errorf("no server matches %s", args[0]))
+	}
+	if len(servers) > 1 && len(command) == 0 {
+		exitOnError(fmt.Errorf("%d servers match %s, a command is required to run on several servers", len(servers), args[0]))
+	}
+	targets := newSshTargets(ctx, servers)
+
+	if len(targets) == 1 {
+		t := targets[0]
+		sshArgs := append(t.args(false), ctx.String("user")+"@"+t.address)
+		execInteractive("ssh", append(sshArgs, command...))
+		return
+	}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, parallel)
+	failed := make([]string, 0)
+	for _, t := range targets {
+		wg.Add(1)
+		go func(t *sshTarget) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			prefix := "[" + t.server.Name + "] "
+			stdout := &prefixWriter{prefix: prefix, out: os.Stdout, mutex: &mutex}
+			stderr := &prefixWriter{prefix: prefix, out: os.Stderr, mutex: &mutex}
+			sshArgs := append(t.args(true), ctx.String("user")+"@"+t.address)
+			cmd := exec.Command("ssh", append(sshArgs, command...)...)
+			cmd.Stdout = stdout
+			cmd.Stderr = stderr
+			err := cmd.Run()
+			stdout.Flush()
+			stderr.Flush()
+			if err != nil {
+				mutex.Lock()
+				failed = append(failed, fmt.Sprintf("%s: %v", t.server.Name, err))
+				mutex.Unlock()
+			}
+		}(t)
+	}
+	wg.Wait()
+
+	if len(failed) > 0 {
+		fmt.Fprintf(os.Stderr, "Command failed on %d of %d servers:\n%s\n", len(failed), len(targets), strings.Join(failed, "\n"))
+		os.Exit(1)
+	}
+}
+
+// Splits an scp argument of the form [user@]server:path into its parts.
+// Local paths, including paths containing a colon after a slash, return ok set to false.
+func splitScpArg(arg string) (user, host, file string, ok bool) {
+	i := strings.Index(arg, ":")
+	if i <= 0 || strings.Contains(arg[:i], "/") {
+		return "", "", "", false
+	}
+	host, file = arg[:i], arg[i+1:]
+	if j := strings.LastIndex(host, "@"); j >= 0 {
+		user, host = host[:j], host[j+1:]
+	}
+	return user, host, file, true
+}
+
+func runScp(ctx *cli.Context) {
+	args := ctx.Args()
+	if len(args) < 2 {
+		exitOnError(fmt.Errorf("source and target are required"))
+	}
+
+	ensureClient(ctx)
+	all, err := api.ListServers()
+	exitOnError(err)
+
+	scpArgs := make([]string, len(args))
+	var identity string
+	remote := 0
+	for i, arg := range args {
+		scpArgs[i] = arg
+		user, host, file, ok := splitScpArg(arg)
+		if !ok {
+			continue
+		}
+		var server *oneandone.Server
+		for j := range all {
+			if all[j].Name == host || all[j].Id == host {
+				server = &all[j]
+				break
+			}
+		}
+		if server == nil {
+			exitOnError(fmt.Errorf("server %s not found", host))
+		}
+		t := newSshTargets(ctx, []*oneandone.Server{server})[0]
+		if user == "" {
+			user = ctx.String("user")
+		}
+		address := t.address
+		if strings.Contains(address, ":") {
+			address = "[" + address + "]"
+		}
+		scpArgs[i] = user + "@" + address + ":" + file
+		if identity == "" {
+			identity = t.identity
+		}
+		remote++
+	}
+	if remote == 0 {
+		exitOnError(fmt.Errorf("either source or target must be a server, e.g. my-server:/tmp/file"))
+	}
+
+	var options []string
+	if identity != "" {
+		options = append(options, "-i", identity)
+	}
+	if ctx.Bool("recursive") {
+		options = append(options, "-r")
+	}
+	execInteractive("scp", append(options, scpArgs...))
+}
@@ -40,6 +40,31 @@ func init() {
 					},
 					Action: createSSHKey,
 				},
+				{
+					Name:   "audit",
+					Usage:  "Lists SSH Keys not attached to any server, shared by several servers or duplicated.",
+					Action: auditSSHKeys,
+				},
+				{
+					Name:  "import",
+					Usage: "Imports public keys from files, skipping keys already in the account.",
+					Flags: []cli.Flag{
+						cli.StringSliceFlag{
+							Name: "file, f",
+							Usage: "Public key file, authorized_keys file or directory with *.pub files. " +
+								"Can be repeated, defaults to ~/.ssh.",
+						},
+						cli.StringFlag{
+							Name:  "desc, d",
+							Usage: "Description of the imported SSH Keys.",
+						},
+						cli.BoolFlag{
+							Name:  "dryrun",
+							Usage: "Only show the keys that would be imported.",
+						},
+					},
+					Action: importSSHKeys,
+				},
 				{
 					Name:   "info",
 					Usage:  "Shows information about an SSH Key.",
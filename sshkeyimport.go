@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/1and1/oneandone-cloudserver-sdk-go"
+	"github.com/codegangsta/cli"
+)
+
+// Bit sizes of the supported key types with a fixed size.
+var sshKeyTypeBits = map[string]int{
+	"ssh-rsa":             0,
+	"ssh-ed25519":         256,
+	"ecdsa-sha2-nistp256": 256,
+	"ecdsa-sha2-nistp384": 384,
+	"ecdsa-sha2-nistp521": 521,
+}
+
+// Public key read from a local file.
+type sshPublicKey struct {
+	Type    string `json:"type"`
+	Bits    int    `json:"bits"`
+	Comment string `json:"comment"`
+	Md5     string `json:"md5"`
+	Sha256  string `json:"sha256"`
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Status  string `json:"status"`
+	blob    string
+}
+
+func (k *sshPublicKey) String() string {
+	return strings.TrimSpace(k.Type + " " + k.blob + " " + k.Comment)
+}
+
+// Reads a length-prefixed string of the SSH wire format.
+func readSshString(data []byte) ([]byte, []byte, error) {
+	if len(data) < 4 {
+		return nil, nil, fmt.Errorf("truncated key data")
+	}
+	n := binary.BigEndian.Uint32(data)
+	if uint32(len(data)-4) < n {
+		return nil, nil, fmt.Errorf("truncated key data")
+	}
+	return data[4 : 4+n], data[4+n:], nil
+}
+
+// Parses a public key line in the authorized_keys format. Options preceding the key type,
+// as allowed in authorized_keys files, are skipped. Only RSA, ECDSA and Ed25519 keys are accepted.
+func parseSshPublicKey(line string) (*sshPublicKey, error) {
+	fields := strings.Fields(line)
+	start := -1
+	for i, f := range fields {
+		if _, ok := sshKeyTypeBits[f]; ok {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return nil, fmt.Errorf("unsupported key type, only RSA, ECDSA and Ed25519 keys are supported")
+	}
+	if len(fields) < start+2 {
+		return nil, fmt.Errorf("missing key data")
+	}
+	key := &sshPublicKey{
+		Type:    fields[start],
+		Bits:    sshKeyTypeBits[fields[start]],
+		Comment: strings.Join(fields[start+2:], " "),
+		blob:    fields[start+1],
+	}
+	blob, err := base64.StdEncoding.DecodeString(key.blob)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key encoding: %v", err)
+	}
+	keyType, rest, err := readSshString(blob)
+	if err != nil {
+		return nil, err
+	}
+	if string(keyType) != key.Type {
+		return nil, fmt.Errorf("key data of type %s does not match key type %s", keyType, key.Type)
+	}
+	if key.Type == "ssh-rsa" {
+		// The public exponent is followed by the modulus.
+		_, rest, err = readSshString(rest)
+		if err == nil {
+			var modulus []byte
+			modulus, _, err = readSshString(rest)
+			key.Bits = new(big.Int).SetBytes(modulus).BitLen()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sum := md5.Sum(blob)
+	hex := make([]string, len(sum))
+	for i, b := range sum {
+		hex[i] = fmt.Sprintf("%02x", b)
+	}
+	key.Md5 = strings.Join(hex, ":")
+	sha := sha256.Sum256(blob)
+	key.Sha256 = "SHA256:" + base64.RawStdEncoding.EncodeToString(sha[:])
+	return key, nil
+}
+
+// Reads the public keys of a file in the authorized_keys format, skipping comments and empty lines.
+// Invalid keys are returned with their status set to the parsing error.
+func readSshPublicKeys(fileName string) ([]*sshPublicKey, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var keys []*sshPublicKey
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, err := parseSshPublicKey(line)
+		if err != nil {
+			key = &sshPublicKey{Status: "invalid: " + err.Error()}
+		}
+		key.File, key.Line = fileName, n
+		keys = append(keys, key)
+	}
+	return keys, scanner.Err()
+}
+
+// Returns the public key files to import. Directories are expanded to the *.pub files they contain,
+// with no paths given the public keys in ~/.ssh are used.
+func getSshPublicKeyFiles(paths []string) []string {
+	if len(paths) == 0 {
+		home, err := os.UserHomeDir()
+		exitOnError(err)
+		paths = []string{filepath.Join(home, ".ssh")}
+	}
+	var files []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		exitOnError(err)
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		pubFiles, err := filepath.Glob(filepath.Join(p, "*.pub"))
+		exitOnError(err)
+		files = append(files, pubFiles...)
+	}
+	if len(files) == 0 {
+		exitOnError(fmt.Errorf("no public key files found"))
+	}
+	return files
+}
+
+// Returns a name for the imported key from its comment or file name, unique among the given names.
+func sshKeyImportName(key *sshPublicKey, names map[string]bool) string {
+	name := key.Comment
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(key.File), ".pub")
+	}
+	unique := name
+	for i := 2; names[unique]; i++ {
+		unique = name + "-" + strconv.Itoa(i)
+	}
+	names[unique] = true
+	return unique
+}
+
+func importSSHKeys(ctx *cli.Context) {
+	var keys []*sshPublicKey
+	for _, fileName := range getSshPublicKeyFiles(ctx.StringSlice("file")) {
+		fileKeys, err := readSshPublicKeys(fileName)
+		exitOnError(err)
+		keys = append(keys, fileKeys...)
+	}
+
+	accountKeys, err := api.ListSSHKeys()
+	exitOnError(err)
+	existing := make(map[string]string)
+	names := make(map[string]bool)
+	for _, k := range accountKeys {
+		existing[normalizeFingerprint(k.Md5)] = k.Name
+		names[k.Name] = true
+	}
+
+	imported := 0
+	for _, key := range keys {
+		if key.Status != "" {
+			continue
+		}
+		if name, ok := existing[normalizeFingerprint(key.Md5)]; ok {
+			key.Status = "exists as " + name
+			continue
+		}
+		name := sshKeyImportName(key, names)
+		existing[normalizeFingerprint(key.Md5)] = name
+		if ctx.Bool("dryrun") {
+			key.Status = "would import as " + name
+			continue
+		}
+		req := oneandone.SSHKeyRequest{
+			Name:        name,
+			Description: ctx.String("desc"),
+			PublicKey:   key.String(),
+		}
+		if _, _, err := api.CreateSSHKey(&req); err != nil {
+			key.Status = "failed: " + err.Error()
+			continue
+		}
+		key.Status = "imported as " + name
+		imported++
+	}
+
+	data := make([][]string, len(keys))
+	for i, k := range keys {
+		data[i] = []string{
+			k.File + ":" + strconv.Itoa(k.Line),
+			k.Type,
+			strconv.Itoa(k.Bits),
+			k.Md5,
+			k.Sha256,
+			k.Status,
+		}
+	}
+	header := []string{"File", "Type", "Bits", "MD5", "SHA256", "Status"}
+	message := fmt.Sprintf("%d of %d keys imported.\n", imported, len(keys))
+	output(ctx, keys, message, false, &header, &data)
+}
+
+// Finding of 'sshkey audit'.
+type sshKeyFinding struct {
+	Id      string   `json:"id"`
+	Name    string   `json:"name"`
+	Md5     string   `json:"md5"`
+	Finding string   `json:"finding"`
+	Servers []string `json:"servers"`
+}
+
+func auditSSHKeys(ctx *cli.Context) {
+	sshKeys, err := api.ListSSHKeys()
+	exitOnError(err)
+
+	var findings []sshKeyFinding
+	byMd5 := make(map[string][]oneandone.SSHKey)
+	for _, key := range sshKeys {
+		byMd5[normalizeFingerprint(key.Md5)] = append(byMd5[normalizeFingerprint(key.Md5)], key)
+		var servers []string
+		if key.Servers != nil {
+			for _, s := range *key.Servers {
+				servers = append(servers, firstNonEmpty(s.Name, s.Id))
+			}
+		}
+		switch {
+		case len(servers) == 0:
+			findings = append(findings, sshKeyFinding{key.Id, key.Name, key.Md5, "not attached to any server", nil})
+		case len(servers) > 1:
+			sort.Strings(servers)
+			findings = append(findings, sshKeyFinding{key.Id, key.Name, key.Md5,
+				fmt.Sprintf("shared by %d servers", len(servers)), servers})
+		}
+	}
+	for _, keys := range byMd5 {
+		if len(keys) < 2 {
+			continue
+		}
+		for _, key := range keys {
+			findings = append(findings, sshKeyFinding{key.Id, key.Name, key.Md5,
+				fmt.Sprintf("same public key as %d other SSH keys", len(keys)-1), nil})
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].Md5 < findings[j].Md5 })
+
+	data := make([][]string, len(findings))
+	for i, f := range findings {
+		data[i] = []string{f.Id, f.Name, f.Md5, f.Finding, strings.Join(f.Servers, ", ")}
+	}
+	header := []string{"ID", "Name", "Md5", "Finding", "Servers"}
+	message := fmt.Sprintf("%d findings for %d SSH keys.\n", len(findings), len(sshKeys))
+	output(ctx, findings, message, false, &header, &data)
+}
@@ -0,0 +1,473 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/1and1/oneandone-cloudserver-sdk-go"
+	"github.com/codegangsta/cli"
+	"github.com/olekukonko/tablewriter"
+)
+
+var topOps []cli.Command
+
+const (
+	clearScreen = "\033[H\033[2J"
+	hideCursor  = "\033[?25l"
+	showCursor  = "\033[?25h"
+	topHelpLine = "[up/down] select  [enter] details  [esc] back  [s] start  [x] stop  [r] reboot  [q] quit"
+)
+
+func init() {
+	topOps = []cli.Command{
+		{
+			Name:        "top",
+			HelpName:    appName + " top",
+			Description: "1&1 live dashboard of servers, monitoring alerts and logs",
+			Usage:       "Live dashboard of servers and alerts.",
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:  "interval",
+					Value: 5,
+					Usage: "Refresh interval in seconds.",
+				},
+				cli.IntFlag{
+					Name:  "logs",
+					Value: 10,
+					Usage: "Number of recent log entries to show.",
+				},
+			},
+			Action: showTop,
+		},
+	}
+}
+
+type topView struct {
+	servers  []oneandone.Server
+	monitors map[string]oneandone.MonServerUsageSummary
+	logs     []oneandone.Log
+	pending  []oneandone.Log
+	selected int
+	details  *oneandone.Server
+	confirm  string
+	message  string
+	updated  time.Time
+	logCount int
+	rows     int
+}
+
+func showTop(ctx *cli.Context) {
+	ensureClient(ctx)
+	interval := validateIntRange("interval", ctx.Int("interval"), 1, 3600)
+	logCount := validateIntRange("logs", ctx.Int("logs"), 0, 100)
+
+	state, err := setRawTerminal()
+	exitOnError(err)
+	// exitOnError must not be used below this point, the terminal has to be restored first.
+	defer restoreTerminal(state)
+	fmt.Print(hideCursor)
+	defer fmt.Print(showCursor)
+
+	view := &topView{logCount: logCount, rows: terminalRows()}
+	view.refresh()
+
+	keys := make(chan string)
+	go readKeys(os.Stdin, keys)
+	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		view.draw(os.Stdout)
+		select {
+		case <-ticker.C:
+			view.rows = terminalRows()
+			view.refresh()
+		case key, ok := <-keys:
+			if !ok || !view.handleKey(key) {
+				fmt.Print(clearScreen)
+				return
+			}
+		}
+	}
+}
+
+func (v *topView) refresh() {
+	v.message = ""
+	servers, err := api.ListServers()
+	if err != nil {
+		v.message = err.Error()
+		return
+	}
+	v.servers = servers
+	if v.selected >= len(v.servers) {
+		v.selected = len(v.servers) - 1
+	}
+	if v.selected < 0 {
+		v.selected = 0
+	}
+
+	monitors, err := api.ListMonitoringServersUsages()
+	if err != nil {
+		v.message = err.Error()
+	}
+	v.monitors = make(map[string]oneandone.MonServerUsageSummary, len(monitors))
+	for _, m := range monitors {
+		v.monitors[m.Id] = m
+	}
+
+	logs, err := api.ListLogs("LAST_24H", nil, nil, 0, 100, "-start_date", "", "")
+	if err != nil {
+		v.message = err.Error()
+	}
+	v.logs = nil
+	v.pending = nil
+	for _, l := range logs {
+		if len(v.logs) < v.logCount {
+			v.logs = append(v.logs, l)
+		}
+		if l.Status != nil && isPendingLogState(l.Status.State) {
+			v.pending = append(v.pending, l)
+		}
+	}
+
+	if v.details != nil {
+		server, err := api.GetServer(v.details.Id)
+		if err != nil {
+			v.message = err.Error()
+		} else {
+			v.details = server
+		}
+	}
+	v.updated = time.Now()
+}
+
+// Returns false when the dashboard should quit.
+func (v *topView) handleKey(key string) bool {
+	if v.confirm != "" {
+		action := v.confirm
+		v.confirm = ""
+		if key == "y" || key == "Y" {
+			v.runAction(action)
+		} else {
+			v.message = "Cancelled."
+		}
+		return true
+	}
+	switch key {
+	case "q", "Q", "\x03":
+		return false
+	case "up", "k":
+		if v.details == nil && v.selected > 0 {
+			v.selected--
+		}
+	case "down", "j":
+		if v.details == nil && v.selected < len(v.servers)-1 {
+			v.selected++
+		}
+	case "enter":
+		if server := v.selectedServer(); server != nil {
+			details, err := api.GetServer(server.Id)
+			if err != nil {
+				v.message = err.Error()
+			} else {
+				v.details = details
+			}
+		}
+	case "esc", "b":
+		v.details = nil
+	case "s", "x", "r":
+		if server := v.selectedServer(); server != nil {
+			v.confirm = key
+			v.message = fmt.Sprintf("%s server '%s'? [y/N]", topActionName(key), server.Name)
+		}
+	case "R":
+		v.refresh()
+	}
+	return true
+}
+
+func (v *topView) runAction(action string) {
+	server := v.selectedServer()
+	if server == nil {
+		return
+	}
+	var err error
+	switch action {
+	case "s":
+		_, err = api.StartServer(server.Id)
+	case "x":
+		_, err = api.ShutdownServer(server.Id, false)
+	case "r":
+		_, err = api.RebootServer(server.Id, false)
+	}
+	if err != nil {
+		v.message = err.Error()
+		return
+	}
+	v.refresh()
+	v.message = fmt.Sprintf("%s of server '%s' requested.", topActionName(action), server.Name)
+}
+
+func (v *topView) selectedServer() *oneandone.Server {
+	if v.details != nil {
+		return v.details
+	}
+	if v.selected >= 0 && v.selected < len(v.servers) {
+		return &v.servers[v.selected]
+	}
+	return nil
+}
+
+func (v *topView) draw(w io.Writer) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s - updated %s\n\n", appHelpName, v.updated.Format("15:04:05"))
+	if v.details != nil {
+		v.drawDetails(&buf)
+	} else {
+		v.drawServers(&buf)
+		v.drawPending(&buf)
+		v.drawLogs(&buf)
+	}
+	fmt.Fprintf(&buf, "\n%s\n", topHelpLine)
+	if v.message != "" {
+		fmt.Fprintf(&buf, "%s\n", v.message)
+	}
+	// The terminal is in raw mode, so every line feed needs a carriage return.
+	fmt.Fprint(w, clearScreen+strings.Replace(buf.String(), "\n", "\r\n", -1))
+}
+
+func (v *topView) drawServers(w io.Writer) {
+	// Keep the selected server visible when the list is longer than the screen.
+	maxRows := v.rows/2 - 4
+	if maxRows < 5 {
+		maxRows = 5
+	}
+	first := 0
+	if v.selected >= maxRows {
+		first = v.selected - maxRows + 1
+	}
+	var data [][]string
+	for i := first; i < len(v.servers) && i < first+maxRows; i++ {
+		s := v.servers[i]
+		marker := ""
+		if i == v.selected {
+			marker = ">"
+		}
+		state, percent := "", ""
+		if s.Status != nil {
+			state = s.Status.State
+			if s.Status.Percent > 0 {
+				percent = strconv.Itoa(s.Status.Percent) + "%"
+			}
+		}
+		data = append(data, []string{marker, s.Name, state, percent, getDatacenter(s.Datacenter), getServerPublicIp(&s), v.alertState(s.Id)})
+	}
+	fmt.Fprintf(w, "Servers (%d)\n", len(v.servers))
+	renderTopTable(w, []string{"", "Name", "State", "Progress", "Data Center", "IP Address", "Alerts"}, data)
+}
+
+func (v *topView) drawPending(w io.Writer) {
+	if len(v.pending) == 0 {
+		return
+	}
+	data := make([][]string, len(v.pending))
+	for i, l := range v.pending {
+		data[i] = []string{l.Action, l.Type, getLogResource(&l), l.StartDate, l.Status.State}
+	}
+	fmt.Fprintf(w, "\nPending Operations (%d)\n", len(v.pending))
+	renderTopTable(w, []string{"Action", "Type", "Resource", "Start Date", "Status"}, data)
+}
+
+func (v *topView) drawLogs(w io.Writer) {
+	if v.logCount == 0 {
+		return
+	}
+	data := make([][]string, len(v.logs))
+	for i, l := range v.logs {
+		state := ""
+		if l.Status != nil {
+			state = l.Status.State
+		}
+		data[i] = []string{l.Action, l.Type, getLogResource(&l), l.StartDate, state}
+	}
+	fmt.Fprint(w, "\nRecent Logs\n")
+	renderTopTable(w, []string{"Action", "Type", "Resource", "Start Date", "Status"}, data)
+}
+
+func (v *topView) drawDetails(w io.Writer) {
+	s := v.details
+	state, percent := "", ""
+	if s.Status != nil {
+		state = s.Status.State
+		percent = strconv.Itoa(s.Status.Percent) + "%"
+	}
+	data := [][]string{
+		{"ID", s.Id},
+		{"Name", s.Name},
+		{"Description", s.Description},
+		{"State", state},
+		{"Progress", percent},
+		{"Server Type", s.ServerType},
+		{"Data Center", getDatacenter(s.Datacenter)},
+		{"Creation Date", s.CreationDate},
+		{"Alerts", v.alertState(s.Id)},
+	}
+	if s.Hardware != nil {
+		hdds := make([]string, len(s.Hardware.Hdds))
+		for i, hdd := range s.Hardware.Hdds {
+			hdds[i] = strconv.Itoa(hdd.Size) + " GB"
+		}
+		data = append(data,
+			[]string{"vCores", strconv.Itoa(s.Hardware.Vcores)},
+			[]string{"Cores per Processor", strconv.Itoa(s.Hardware.CoresPerProcessor)},
+			[]string{"RAM (GB)", strconv.FormatFloat(float64(s.Hardware.Ram), 'f', -1, 32)},
+			[]string{"Hard Disks", strings.Join(hdds, ", ")},
+		)
+	}
+	if s.Image != nil {
+		data = append(data, []string{"Image", s.Image.Name})
+	}
+	for _, ip := range s.Ips {
+		data = append(data, []string{"IP Address", ip.Ip})
+	}
+	for _, pn := range s.PrivateNets {
+		data = append(data, []string{"Private Network", pn.Name + " " + pn.ServerIP})
+	}
+	renderTopTable(w, []string{"Property", "Value"}, data)
+}
+
+func (v *topView) alertState(serverId string) string {
+	m, ok := v.monitors[serverId]
+	if !ok || m.Status == nil {
+		return ""
+	}
+	state := m.Status.State
+	if m.Alerts != nil {
+		warnings, criticals := 0, 0
+		if m.Alerts.Resources != nil {
+			warnings += m.Alerts.Resources.Warning
+			criticals += m.Alerts.Resources.Critical
+		}
+		if m.Alerts.Ports != nil {
+			warnings += m.Alerts.Ports.Warning
+			criticals += m.Alerts.Ports.Critical
+		}
+		if m.Alerts.Process != nil {
+			warnings += m.Alerts.Process.Warning
+			criticals += m.Alerts.Process.Critical
+		}
+		if warnings+criticals > 0 {
+			state += fmt.Sprintf(" (%d critical, %d warning)", criticals, warnings)
+		}
+	}
+	return state
+}
+
+func renderTopTable(w io.Writer, header []string, data [][]string) {
+	table := tablewriter.NewWriter(w)
+	table.SetAutoWrapText(false)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetHeader(header)
+	table.AppendBulk(data)
+	table.Render()
+}
+
+func topActionName(action string) string {
+	switch action {
+	case "s":
+		return "Start"
+	case "x":
+		return "Stop"
+	case "r":
+		return "Reboot"
+	}
+	return ""
+}
+
+// Log states that do not mean the operation is finished.
+func isPendingLogState(state string) bool {
+	switch state {
+	case "OK", "ERROR", "FAILED", "CANCELED":
+		return false
+	}
+	return true
+}
+
+func getLogResource(l *oneandone.Log) string {
+	if l.Resource != nil {
+		return l.Resource.Name
+	}
+	return ""
+}
+
+func getServerPublicIp(server *oneandone.Server) string {
+	if len(server.Ips) > 0 {
+		return server.Ips[0].Ip
+	}
+	return ""
+}
+
+// Reads key presses and sends them to the channel, escape sequences of arrow keys are translated.
+func readKeys(r io.Reader, keys chan<- string) {
+	buf := make([]byte, 8)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			close(keys)
+			return
+		}
+		switch in := string(buf[:n]); in {
+		case "\x1b[A", "\x1bOA":
+			keys <- "up"
+		case "\x1b[B", "\x1bOB":
+			keys <- "down"
+		case "\x1b":
+			keys <- "esc"
+		case "\r", "\n":
+			keys <- "enter"
+		default:
+			if n == 1 {
+				keys <- in
+			}
+		}
+	}
+}
+
+func setRawTerminal() (string, error) {
+	state, err := stty("-g")
+	if err != nil {
+		return "", fmt.Errorf("top requires an interactive terminal: %s", err.Error())
+	}
+	_, err = stty("raw", "-echo")
+	return strings.TrimSpace(state), err
+}
+
+func restoreTerminal(state string) {
+	stty(state)
+}
+
+func terminalRows() int {
+	size, err := stty("size")
+	if err == nil {
+		fields := strings.Fields(size)
+		if len(fields) == 2 {
+			if rows, err := strconv.Atoi(fields[0]); err == nil {
+				return rows
+			}
+		}
+	}
+	return 24
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
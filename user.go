@@ -61,15 +61,14 @@ func init() {
 				{
 					Name:  "create",
 					Usage: "Creates new user.",
-					Flags: []cli.Flag{
+					Flags: append([]cli.Flag{
 						cli.StringFlag{
 							Name:  "name, n",
 							Usage: "Username.",
 						},
-						userPassFlag,
 						userDescFlag,
 						userEmailFlag,
-					},
+					}, secretFlags(userPassFlag)...),
 					Action: createUser,
 				},
 				{
@@ -117,16 +116,15 @@ func init() {
 				{
 					Name:  "modify",
 					Usage: "Modifies user.",
-					Flags: []cli.Flag{
+					Flags: append([]cli.Flag{
 						userIdFlag,
-						userPassFlag,
 						userDescFlag,
 						userEmailFlag,
 						cli.StringFlag{
 							Name:  "status",
 							Usage: "Enable or disable user: ACTIVE or DISABLED.",
 						},
-					},
+					}, secretFlags(userPassFlag)...),
 					Action: modifyUser,
 				},
 				{
@@ -153,7 +151,7 @@ func init() {
 
 func createUser(ctx *cli.Context) {
 	name := getRequiredOption(ctx, "name")
-	password := getRequiredOption(ctx, "password")
+	password, generated := getSecret(ctx, "password", true)
 
 	req := oneandone.UserRequest{
 		Name:        name,
@@ -163,6 +161,9 @@ func createUser(ctx *cli.Context) {
 	}
 	_, user, err := api.CreateUser(&req)
 	exitOnError(err)
+	if generated {
+		saveSecret(ctx, "user "+name, user.Id, password)
+	}
 	output(ctx, user, okWaitMessage, false, nil, nil)
 }
 
@@ -208,14 +209,18 @@ func modifyUser(ctx *cli.Context) {
 		exitOnError(fmt.Errorf("Invalid value for --status flag. Valid values are ACTIVE and DISABLE."))
 	}
 
+	password, generated := getSecret(ctx, "password", false)
 	req := oneandone.UserRequest{
-		Password:    ctx.String("password"),
+		Password:    password,
 		Description: ctx.String("desc"),
 		Email:       ctx.String("email"),
 		State:       status,
 	}
 	user, err := api.ModifyUser(id, &req)
 	exitOnError(err)
+	if generated {
+		saveSecret(ctx, "user "+user.Name, user.Id, password)
+	}
 	output(ctx, user, okWaitMessage, false, nil, nil)
 }
 